@@ -6,6 +6,7 @@ require (
 	github.com/anchore/clio v0.0.0-20250908162139-4390b5d3d46e
 	github.com/anchore/fangs v0.0.0-20250908220736-f686ade3235a
 	github.com/anchore/go-logger v0.0.0-20250813181427-74728f89a619
+	github.com/bmatcuk/doublestar/v4 v4.10.2
 	github.com/charmbracelet/lipgloss v1.1.0
 	github.com/charmbracelet/x/ansi v0.10.1
 	github.com/charmbracelet/x/term v0.2.1
@@ -16,6 +17,7 @@ require (
 	github.com/heimdalr/dag v1.5.0
 	github.com/pborman/indent v1.2.1
 	github.com/rs/xid v1.6.0
+	github.com/santhosh-tekuri/jsonschema/v6 v6.0.3
 	github.com/spf13/cobra v1.10.1
 	github.com/wagoodman/go-partybus v0.0.0-20230516145632-8ccac152c651
 	golang.org/x/mod v0.28.0
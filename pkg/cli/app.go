@@ -9,20 +9,48 @@ import (
 	"github.com/vbehar/mason/pkg/masonry"
 )
 
-var mason = masonry.NewMason()
+var (
+	mason = masonry.NewMason()
+	ui    = &UI{Output: os.Stdout}
+)
 
 func Application(id clio.Identification) clio.Application {
 	app := clio.New(*clioSetupConfig(id))
 
 	rootCmd := app.SetupRootCommand(rootCommand(id), masonConfig)
+	configCmd := clio.ConfigCommand(app, &clio.ConfigCommandConfig{
+		IncludeLocationsSubcommand: true,
+		LoadConfig:                 true,
+		ReplaceHomeDirWithTilde:    true,
+	})
+	// to show the active blueprint profile
+	configCmd.Flags().StringVar(&masonConfig.Profile, "blueprint-profile", masonConfig.Profile, "Profile to apply to the blueprints")
+	graphCmd, graphOpts := graphCommand()
+	bricksCmd, bricksOpts := bricksCommand()
+	schemaCmd, schemaOpts := schemaCommand()
+	modulesCmd := modulesCommand()
+	modulesUpgradeCmd, modulesUpgradeOpts := modulesUpgradeCommand()
+	modulesCmd.AddCommand(
+		app.SetupCommand(modulesListCommand(), masonConfig),
+		app.SetupCommand(modulesUpgradeCmd, masonConfig, modulesUpgradeOpts),
+	)
+	describeCmd := describeCommand()
+	describeCmd.AddCommand(
+		app.SetupCommand(describeBrickCommand(), masonConfig),
+	)
 	rootCmd.AddCommand(
 		app.SetupCommand(phasesCommand(), masonConfig),
+		app.SetupCommand(planCommand(), masonConfig),
+		app.SetupCommand(graphCmd, masonConfig, graphOpts),
+		app.SetupCommand(bricksCmd, masonConfig, bricksOpts),
+		app.SetupCommand(explainCommand(), masonConfig),
+		app.SetupCommand(validateBlueprintCommand(), masonConfig),
+		app.SetupCommand(schemaCmd, masonConfig, schemaOpts),
+		app.SetupCommand(lockCommand(), masonConfig),
+		modulesCmd,
+		describeCmd,
 		clio.VersionCommand(id, daggerVersion),
-		clio.ConfigCommand(app, &clio.ConfigCommandConfig{
-			IncludeLocationsSubcommand: true,
-			LoadConfig:                 true,
-			ReplaceHomeDirWithTilde:    true,
-		}),
+		configCmd,
 	)
 
 	return app
@@ -42,9 +70,8 @@ func clioSetupConfig(id clio.Identification) *clio.SetupConfig {
 				} else {
 					output = os.Stdout
 				}
-				return clio.NewUICollection(&UI{
-					Output: output,
-				}), nil
+				ui.Output = output
+				return clio.NewUICollection(ui), nil
 			},
 		).
 		WithInitializers(func(state *clio.State) error {
@@ -52,6 +79,7 @@ func clioSetupConfig(id clio.Identification) *clio.SetupConfig {
 			masonConfig.state = state
 			mason.EventBus = state.Bus
 			mason.Logger = state.Logger
+			mason.RedactStore = state.RedactStore
 			if state.Config.Log.Quiet {
 				mason.DaggerOutputDisabled = true
 			}
@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/anchore/clio"
+	"github.com/goccy/go-yaml"
+	"github.com/spf13/cobra"
+	"github.com/vbehar/mason/pkg/masonry"
+)
+
+var bricksFormats = []string{"table", "json", "yaml"}
+
+var _ interface {
+	clio.FlagAdder
+	clio.PostLoader
+} = (*bricksOptions)(nil)
+
+type bricksOptions struct {
+	Format string `mapstructure:"format"`
+}
+
+func (o *bricksOptions) AddFlags(flags clio.FlagSet) {
+	flags.StringVarP(&o.Format, "format", "o", fmt.Sprintf("Output format, one of %v", bricksFormats))
+}
+
+func (o *bricksOptions) PostLoad() error {
+	if !slices.Contains(bricksFormats, o.Format) {
+		return fmt.Errorf("invalid format %q: must be one of %v", o.Format, bricksFormats)
+	}
+	return nil
+}
+
+// brickListItem is a brick, as listed by the bricks command.
+type brickListItem struct {
+	Workspace   string            `json:"workspace"`
+	Name        string            `json:"name"`
+	Kind        string            `json:"kind"`
+	Module      string            `json:"module"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	PostRun     string            `json:"postRun,omitempty"`
+	ExtraPhases []string          `json:"extraPhases,omitempty"`
+	Source      string            `json:"source"`
+}
+
+func bricksCommand() (*cobra.Command, *bricksOptions) {
+	opts := &bricksOptions{
+		Format: "table",
+	}
+	return &cobra.Command{
+		Use:   "bricks",
+		Short: "List the bricks loaded from the blueprints",
+		Long: `List the bricks loaded from the blueprints of all the workspaces,
+once the includes, profiles, matrices and conditions are applied.
+
+The label selector is evaluated exactly like when running the phases - including the implicit
+module, kind and name labels, and the post-run bricks which are always kept - so that it can be
+tested before running any phase.`,
+		Example: `  # List all the bricks
+  mason bricks
+
+  # Test a label selector
+  mason bricks -l 'kind=GoBinary,os in (linux)'
+
+  # List the bricks as JSON
+  mason bricks -o json | jq -r '.[].name'`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return listBricks(opts)
+		},
+	}, opts
+}
+
+func listBricks(opts *bricksOptions) error {
+	ui.reserveStdout()
+
+	workspaces, err := mason.DetectWorkspaces()
+	if err != nil {
+		return err
+	}
+	if len(workspaces) == 0 {
+		return fmt.Errorf("no .mason directory found")
+	}
+
+	var (
+		mutex            sync.Mutex
+		itemsByWorkspace = make(map[string][]brickListItem)
+	)
+	err = mason.ForEachWorkspace(workspaces, func(workspace masonry.Workspace) error {
+		blueprint, err := workspace.LoadBlueprint()
+		if err != nil {
+			return err
+		}
+		*blueprint = blueprint.Filter(masonConfig.labelSelector)
+
+		var items []brickListItem
+		for _, brick := range blueprint.Bricks {
+			items = append(items, brickListItem{
+				Workspace:   workspace.RelativePath,
+				Name:        brick.Metadata.Name,
+				Kind:        brick.Kind,
+				Module:      string(brick.ModuleRef),
+				Labels:      brick.Metadata.Labels,
+				PostRun:     string(brick.Metadata.PostRun),
+				ExtraPhases: brick.Metadata.ExtraPhases,
+				Source:      brick.Source.String(),
+			})
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		itemsByWorkspace[workspace.RelativePath] = items
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	items := []brickListItem{}
+	for _, workspace := range workspaces {
+		items = append(items, itemsByWorkspace[workspace.RelativePath]...)
+	}
+
+	switch opts.Format {
+	case "json":
+		data, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode bricks: %w", err)
+		}
+		fmt.Println(string(data))
+	case "yaml":
+		data, err := yaml.Marshal(items)
+		if err != nil {
+			return fmt.Errorf("failed to encode bricks: %w", err)
+		}
+		fmt.Print(string(data))
+	default:
+		fmt.Println(bricksTable(items))
+	}
+	return nil
+}
+
+func bricksTable(items []brickListItem) string {
+	bricksTable := newTable("WORKSPACE", "NAME", "KIND", "MODULE", "LABELS", "POSTRUN", "EXTRA PHASES")
+	for _, item := range items {
+		var labels []string
+		for _, key := range slices.Sorted(maps.Keys(item.Labels)) {
+			labels = append(labels, key+"="+item.Labels[key])
+		}
+		bricksTable.Row(
+			item.Workspace,
+			item.Name,
+			item.Kind,
+			item.Module,
+			strings.Join(labels, ","),
+			item.PostRun,
+			strings.Join(item.ExtraPhases, ","),
+		)
+	}
+	return bricksTable.String()
+}
@@ -13,6 +13,8 @@ var masonConfig = &MasonConfig{
 	RootPath:    ".",
 	IgnoredDirs: []string{".git"},
 	KeepWorkDir: false,
+	Parallelism: 1,
+	Strict:      true,
 	Dagger: DaggerConfig{
 		Binary: "dagger",
 		Args: []string{
@@ -35,6 +37,12 @@ type MasonConfig struct {
 	RootPath    string   `mapstructure:"root-path"`
 	IgnoredDirs []string `mapstructure:"ignored-dirs"`
 	KeepWorkDir bool     `mapstructure:"keep-work-dir"`
+	Parallelism int      `mapstructure:"parallelism"`
+	Since       string   `mapstructure:"since"`
+	Strict      bool     `mapstructure:"strict"`
+	Set         []string `mapstructure:"set"`
+	Profile     string   `mapstructure:"blueprint-profile"`
+	Locked      bool     `mapstructure:"locked"`
 
 	BrickLabelSelector string `mapstructure:"label-selector"`
 	labelSelector      labels.Selector
@@ -50,15 +58,46 @@ func (c *MasonConfig) AddFlags(flags clio.FlagSet) {
 	flags.StringVarP(&c.RootPath, "root-path", "", "Root path of the workspace")
 	flags.StringArrayVarP(&c.IgnoredDirs, "ignored-dirs", "", "Directories to ignore")
 	flags.BoolVarP(&c.KeepWorkDir, "keep-work-dir", "", "Keep the work directory after execution")
+	flags.IntVarP(&c.Parallelism, "parallelism", "", "Maximum number of workspaces to run at the same time")
+	flags.StringVarP(&c.Since, "since", "", "Only run the workspaces and bricks affected by the changes since this git reference")
+	flags.BoolVarP(&c.Strict, "strict", "", "Fail on invalid or duplicate bricks instead of skipping them")
+	flags.StringArrayVarP(&c.Set, "set", "", "Set a variable for the templated values of the bricks, as key=value")
+	flags.StringVarP(&c.Profile, "blueprint-profile", "", "Profile to apply to the blueprints, from the .mason/profiles/<profile> directories")
+	flags.BoolVarP(&c.Locked, "locked", "", "Fail if a module is not pinned in the lock file of its workspace")
 	flags.StringVarP(&c.BrickLabelSelector, "selector", "l", "Label selector for bricks, similar to Kubernetes Label selector syntax. "+
 		"Note that the brick kind and name can be used as labels.")
 }
 
 func (c *MasonConfig) DescribeFields(d clio.FieldDescriptionSet) {
+	d.Add(&c.Parallelism, "Maximum number of workspaces to run at the same time. Each workspace renders and applies its own plans.")
+	d.Add(&c.Since, "Git reference (commit, branch, tag) to compare the working tree with. "+
+		"Only the workspaces owning a changed file - or depending on such a workspace - are run, "+
+		"and only the bricks whose metadata.inputs globs match a changed file.")
+	d.Add(&c.Strict, "Fail when the blueprint has an invalid brick - missing fields, unknown fields - or 2 bricks with the same kind and name. "+
+		"When disabled, such bricks are skipped with a warning.")
+	d.Add(&c.Set, "Variables for the templated values of the bricks - available as {{ .vars.<key> }} - as key=value. "+
+		"They override the variables defined in the workspaces config files.")
+	d.Add(&c.Profile, "Active blueprint profile, such as local, ci or release. The overlay files of the .mason/profiles/<profile> directory "+
+		"of each workspace are applied to its blueprint: they patch, add or delete bricks.")
+	d.Add(&c.Locked, "Fail if a module used by a blueprint is not pinned in the .mason/mason.lock file of its workspace - see mason lock. "+
+		"The modules pinned in the lock files are always used, even without this option.")
 	d.Add(&c.Aliases, "Aliases for phases. Each alias is a list of labels that will be used to select bricks for the phase.")
 }
 
 func (c *MasonConfig) PostLoad() error {
+	if c.Parallelism < 1 {
+		return fmt.Errorf("invalid parallelism %d: must be at least 1", c.Parallelism)
+	}
+
+	variables := make(map[string]string, len(c.Set))
+	for _, variable := range c.Set {
+		key, value, ok := strings.Cut(variable, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid variable %q: must be key=value", variable)
+		}
+		variables[key] = value
+	}
+
 	var err error
 	c.labelSelector, err = labels.Parse(c.BrickLabelSelector)
 	if err != nil {
@@ -86,12 +125,26 @@ func (c *MasonConfig) PostLoad() error {
 		c.Dagger.Args = append(c.Dagger.Args, "--quiet=1")
 	}
 
+	// when running multiple workspaces at the same time, the Dagger TUIs would overwrite each other
+	// so we switch to the plain progress output - unless the user specified a progress flag
+	hasProgressFlag := slices.ContainsFunc(c.Dagger.Args, func(arg string) bool {
+		return strings.HasPrefix(arg, "--progress")
+	})
+	if !hasProgressFlag && c.Parallelism > 1 {
+		c.Dagger.Args = append(c.Dagger.Args, "--progress=plain")
+	}
+
 	// now that our config is loaded, we can use it
 	mason.RootPath = c.RootPath
 	mason.IgnoredDirs = c.IgnoredDirs
 	mason.DaggerArgs = c.Dagger.Args
 	mason.DaggerEnv = c.Dagger.Env
 	mason.DaggerBinary = c.Dagger.Binary
+	mason.Parallelism = c.Parallelism
+	mason.StrictBlueprint = c.Strict
+	mason.Variables = variables
+	mason.Profile = c.Profile
+	mason.Locked = c.Locked
 	return nil
 }
 
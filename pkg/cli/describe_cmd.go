@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/pborman/indent"
+	"github.com/spf13/cobra"
+	"github.com/vbehar/mason/pkg/masonry"
+)
+
+func describeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "describe",
+		Short: "Describe the resources loaded by Mason",
+		Args:  cobra.NoArgs,
+	}
+}
+
+func describeBrickCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "brick <name>",
+		Short: "Describe a brick after all the processing, with the scripts rendered by its module",
+		Long: `Describe a brick after all the processing of its blueprint - includes, inheritance, profiles,
+spec defaults, matrices, templated values and conditions - with its source, the JSON sent to its module,
+and the Dagger scripts rendered by its module, as Mason interprets their file names.
+
+The brick is rendered on its own, without the bricks it depends on.
+The name is case-insensitive, and can be prefixed by the kind of the brick: <kind>/<name>.
+The bricks with this name are described in all the workspaces.`,
+		Example: `  # Describe a brick
+  mason describe brick mason-linux-amd64
+
+  # Describe the brick of a given kind
+  mason describe brick GoBinary/mason-linux-amd64`,
+		Args: cobra.ExactArgs(1),
+		RunE: describeBrick,
+	}
+}
+
+func describeBrick(_ *cobra.Command, args []string) error {
+	ui.reserveStdout()
+
+	workspaces, err := mason.DetectWorkspaces()
+	if err != nil {
+		return err
+	}
+	if len(workspaces) == 0 {
+		return fmt.Errorf("no .mason directory found")
+	}
+
+	var found atomic.Int32
+	err = mason.ForEachWorkspace(workspaces, func(workspace masonry.Workspace) error {
+		blueprint, err := workspace.LoadBlueprint()
+		if err != nil {
+			return err
+		}
+
+		var sb strings.Builder
+		for _, brick := range blueprint.FindBricks(args[0]) {
+			found.Add(1)
+			description, err := blueprint.DescribeBrick(brick)
+			if err != nil {
+				return fmt.Errorf("failed to describe brick %s %s: %w", brick.Kind, brick.Metadata.Name, err)
+			}
+			sb.WriteString(brickDescription(workspace, *description))
+		}
+		// print each workspace at once, to avoid mixing the output of workspaces running in parallel
+		fmt.Print(sb.String())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if found.Load() == 0 {
+		return fmt.Errorf("no brick %q found", args[0])
+	}
+	return nil
+}
+
+func brickDescription(workspace masonry.Workspace, description masonry.BrickDescription) string {
+	bold := lipgloss.NewStyle().Bold(true)
+	brick := description.Brick
+
+	var sb strings.Builder
+	sb.WriteString(bold.Render(fmt.Sprintf("Brick %s %s", brick.Kind, brick.Metadata.Name)) + "\n")
+	sb.WriteString(fmt.Sprintf("Workspace: %s\n", workspace.RelativePath))
+	sb.WriteString(fmt.Sprintf("Source:    %s\n", brick.Source))
+	sb.WriteString(fmt.Sprintf("Module:    %s\n", brick.ModuleRef))
+	sb.WriteString("\n" + bold.Render("JSON sent to the module:") + "\n")
+	sb.WriteString(indent.String("  ", description.JSON) + "\n")
+	sb.WriteString("\n" + bold.Render("Scripts rendered by the module:") + "\n")
+	if len(description.Scripts) == 0 {
+		sb.WriteString("  none\n\n")
+		return sb.String()
+	}
+
+	scriptsTable := newTable("FILE", "PHASE", "POSTRUN", "NAME")
+	for _, script := range description.Scripts {
+		phase := script.Phase
+		if phase == "" {
+			phase = "(all)"
+		}
+		scriptsTable.Row(filepath.Base(script.FilePath), phase, string(script.PostRun), script.Name)
+	}
+	sb.WriteString(indent.String("  ", scriptsTable.String()) + "\n\n")
+	return sb.String()
+}
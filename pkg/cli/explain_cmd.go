@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss/tree"
+	"github.com/spf13/cobra"
+	"github.com/vbehar/mason/pkg/masonry"
+)
+
+func explainCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain [phase|alias]",
+		Short: "Explain why each brick is selected - or not - by the label selector",
+		Long: `Evaluate the label selector against each brick of the blueprints, as when running a phase,
+and print every requirement of the selector, with the value of the label of the brick and whether it matched.
+The implicit module, kind and name labels are included.
+
+With an alias, the selectors of its phases are used, merged with the global label selector.
+The post-run bricks are always kept, even if they don't match the selector: they are reported as such.`,
+		Example: `  # Explain a label selector
+  mason explain -l 'kind=GoBinary,os in (linux)'
+
+  # Explain the selectors of an alias, merged with the global label selector
+  mason explain ci -l os=linux`,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: phasesValidArgsFunction,
+		RunE:              explain,
+	}
+}
+
+func explain(_ *cobra.Command, args []string) error {
+	ui.reserveStdout()
+
+	workspaces, err := mason.DetectWorkspaces()
+	if err != nil {
+		return err
+	}
+	if len(workspaces) == 0 {
+		return fmt.Errorf("no .mason directory found")
+	}
+
+	phases := []AliasConfig{{
+		BrickLabelSelector: masonConfig.BrickLabelSelector,
+		labelSelector:      masonConfig.labelSelector,
+	}}
+	// the requirements merged in the selectors of an alias from the global label selector
+	globalRequirements := make(map[string]bool)
+	if len(args) > 0 {
+		phases = parsePhasesAndSelectors(args)
+		if _, ok := masonConfig.Aliases[args[0]]; ok {
+			requirements, _ := masonConfig.labelSelector.Requirements()
+			for _, requirement := range requirements {
+				globalRequirements[requirement.String()] = true
+			}
+		}
+	}
+
+	// the conditions of the bricks are evaluated against the requested phases
+	mason.Phases = nil
+	for _, phaseCfg := range phases {
+		if phaseCfg.Phase != "" {
+			mason.Phases = append(mason.Phases, phaseCfg.Phase)
+		}
+	}
+	return mason.ForEachWorkspace(workspaces, func(workspace masonry.Workspace) error {
+		blueprint, err := workspace.LoadBlueprint()
+		if err != nil {
+			return err
+		}
+
+		var sb strings.Builder
+		for _, phaseCfg := range phases {
+			description := "Workspace " + workspace.RelativePath
+			if phaseCfg.Phase != "" {
+				description += " - phase " + phaseCfg.Phase
+			}
+			if phaseCfg.BrickLabelSelector == "" {
+				description += " - no selector: all the bricks are selected"
+			} else {
+				description += " - selector " + phaseCfg.BrickLabelSelector
+			}
+			root := tree.Root(description)
+
+			for _, selection := range blueprint.ExplainFilter(phaseCfg.labelSelector) {
+				brick := selection.Brick
+				var brickNode *tree.Tree
+				switch {
+				case selection.Matches:
+					brickNode = tree.Root(fmt.Sprintf("✔ %s %s - selected", brick.Kind, brick.Metadata.Name))
+				case selection.PostRun:
+					brickNode = tree.Root(fmt.Sprintf("✔ %s %s - not selected, but kept because it is a post-run brick (%s)",
+						brick.Kind, brick.Metadata.Name, brick.Metadata.PostRun))
+				default:
+					brickNode = tree.Root(fmt.Sprintf("✘ %s %s - not selected", brick.Kind, brick.Metadata.Name))
+				}
+
+				for _, requirement := range selection.Requirements {
+					mark := "✘"
+					if requirement.Matches {
+						mark = "✔"
+					}
+					value := fmt.Sprintf("no %s label", requirement.Key)
+					if requirement.HasLabel {
+						value = fmt.Sprintf("%s is %q", requirement.Key, requirement.Value)
+					}
+					origin := ""
+					if globalRequirements[requirement.Requirement] {
+						origin = " (from the global selector)"
+					}
+					brickNode.Child(fmt.Sprintf("%s %s%s - %s", mark, requirement.Requirement, origin, value))
+				}
+				root.Child(brickNode)
+			}
+			sb.WriteString(root.Enumerator(tree.RoundedEnumerator).String() + "\n")
+		}
+		// print each workspace at once, to avoid mixing the output of workspaces running in parallel
+		fmt.Print(sb.String())
+		return nil
+	})
+}
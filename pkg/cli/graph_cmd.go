@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/anchore/clio"
+	"github.com/spf13/cobra"
+	"github.com/vbehar/mason/pkg/masonry"
+)
+
+var graphFormats = []string{"dot", "mermaid", "json"}
+
+var _ interface {
+	clio.FlagAdder
+	clio.PostLoader
+} = (*graphOptions)(nil)
+
+type graphOptions struct {
+	Format string `mapstructure:"format"`
+}
+
+func (o *graphOptions) AddFlags(flags clio.FlagSet) {
+	flags.StringVarP(&o.Format, "format", "o", fmt.Sprintf("Output format, one of %v", graphFormats))
+}
+
+func (o *graphOptions) PostLoad() error {
+	if !slices.Contains(graphFormats, o.Format) {
+		return fmt.Errorf("invalid format %q: must be one of %v", o.Format, graphFormats)
+	}
+	return nil
+}
+
+func graphCommand() (*cobra.Command, *graphOptions) {
+	opts := &graphOptions{
+		Format: "dot",
+	}
+	return &cobra.Command{
+		Use:   "graph [phase]",
+		Short: "Print the graph of the scripts of a phase, linked by the variables they define and use",
+		Long: `Render the plan of the given phase - or alias - for each workspace,
+and print the graph of its scripts, as Mason orders them before merging them:
+each script is a node, grouped by module, and each edge is labelled by the variables
+defined by a script and used by the other one.`,
+		Example: `  # Render the graph of the package phase with Graphviz
+  mason graph package | dot -Tsvg > package.svg
+
+  # Print the graph of the package phase as a Mermaid flowchart
+  mason graph package --format mermaid`,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: phasesValidArgsFunction,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return printGraphs(args, opts)
+		},
+	}, opts
+}
+
+func printGraphs(args []string, opts *graphOptions) error {
+	ui.reserveStdout()
+	return forEachPlan(args, func(_ masonry.Workspace, plan *masonry.Plan) error {
+		graph, err := plan.Graph()
+		if err != nil {
+			return err
+		}
+
+		var output string
+		switch opts.Format {
+		case "dot":
+			output = graph.DOT()
+		case "mermaid":
+			output = graph.Mermaid()
+		case "json":
+			data, err := json.MarshalIndent(graph, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode graph: %w", err)
+			}
+			output = string(data) + "\n"
+		}
+		// print each graph at once, to avoid mixing the output of workspaces running in parallel
+		fmt.Print(output)
+		return nil
+	})
+}
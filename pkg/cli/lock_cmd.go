@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/charmbracelet/lipgloss/tree"
+	"github.com/spf13/cobra"
+	"github.com/vbehar/mason/pkg/masonry"
+)
+
+func lockCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "lock",
+		Short: "Pin the modules used by the blueprints to their commits",
+		Long: `Pin the modules used by the blueprints to their commits.
+
+For each workspace, resolve the modules referenced by the bricks to their commits - with Dagger -
+and write them to the .mason/mason.lock file. The pinned references are then used to render the plans,
+so that a moved tag doesn't change the build. Use --locked to fail if a module is missing from the lock file.`,
+		Example: `  # Write or update the lock files
+  mason lock
+
+  # Run the package phase with the pinned modules only
+  mason --locked package`,
+		Args: cobra.NoArgs,
+		RunE: lockModules,
+	}
+}
+
+func lockModules(_ *cobra.Command, _ []string) error {
+	ui.reserveStdout()
+
+	workspaces, err := mason.DetectWorkspaces()
+	if err != nil {
+		return err
+	}
+	if len(workspaces) == 0 {
+		return fmt.Errorf("no .mason directory found")
+	}
+
+	return mason.ForEachWorkspace(workspaces, func(workspace masonry.Workspace) error {
+		// all the bricks, whatever their conditions: they depend on the phases, which are unknown here
+		blueprint, err := workspace.LoadBlueprintWithOpts(masonry.LoadBlueprintOpts{SkipConditions: true})
+		if err != nil {
+			return err
+		}
+
+		lock, err := blueprint.Lock()
+		if err != nil {
+			return err
+		}
+		err = workspace.WriteLock(*lock)
+		if err != nil {
+			return err
+		}
+
+		root := tree.Root("Workspace " + workspace.RelativePath + " - " + workspace.LockFilePath())
+		for _, moduleRef := range slices.Sorted(maps.Keys(lock.Modules)) {
+			lockedModule := lock.Modules[moduleRef]
+			description := fmt.Sprintf("%s → %s", moduleRef, lockedModule.Commit)
+			if previous, ok := workspace.Lock.Modules[moduleRef]; ok && previous.Commit != lockedModule.Commit {
+				description += fmt.Sprintf(" (was %s)", previous.Commit)
+			}
+			root.Child(description)
+		}
+		// print each workspace at once, to avoid mixing the output of workspaces running in parallel
+		fmt.Println(root.Enumerator(tree.RoundedEnumerator))
+		return nil
+	})
+}
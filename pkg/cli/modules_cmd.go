@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/anchore/clio"
+	"github.com/charmbracelet/lipgloss/tree"
+	"github.com/spf13/cobra"
+	"github.com/vbehar/mason/pkg/masonry"
+)
+
+func modulesCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "modules",
+		Short: "List and upgrade the modules used by the blueprints",
+		Args:  cobra.NoArgs,
+	}
+}
+
+func modulesListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the modules used by the blueprints, with the bricks using them",
+		Long: `List the modules used by the blueprints, with the bricks using them.
+
+For each workspace, print every distinct module reference used by the bricks - after the resolution
+of the module aliases - with its aliases, the commit it is pinned to in the lock file,
+and the bricks using it.`,
+		Example: `  # List the modules of all the workspaces
+  mason modules list`,
+		Args: cobra.NoArgs,
+		RunE: listModules,
+	}
+}
+
+func listModules(_ *cobra.Command, _ []string) error {
+	ui.reserveStdout()
+
+	workspaces, err := mason.DetectWorkspaces()
+	if err != nil {
+		return err
+	}
+	if len(workspaces) == 0 {
+		return fmt.Errorf("no .mason directory found")
+	}
+
+	return mason.ForEachWorkspace(workspaces, func(workspace masonry.Workspace) error {
+		// all the bricks, whatever their conditions: they depend on the phases, which are unknown here
+		blueprint, err := workspace.LoadBlueprintWithOpts(masonry.LoadBlueprintOpts{SkipConditions: true})
+		if err != nil {
+			return err
+		}
+
+		root := tree.Root("Workspace " + workspace.RelativePath)
+		for _, usage := range blueprint.ModuleUsages() {
+			description := string(usage.ModuleRef)
+			if len(usage.Aliases) > 0 {
+				description += fmt.Sprintf(" (alias %s)", strings.Join(usage.Aliases, ", "))
+			}
+			switch {
+			case usage.Locked != nil:
+				description += " - pinned to " + usage.Locked.Commit
+			case !usage.ModuleRef.IsLocal():
+				description += " - not pinned"
+			}
+			moduleNode := tree.Root(description)
+			for _, brick := range usage.Bricks {
+				moduleNode.Child(fmt.Sprintf("%s %s - %s", brick.Kind, brick.Metadata.Name, brick.Source))
+			}
+			root.Child(moduleNode)
+		}
+		// print each workspace at once, to avoid mixing the output of workspaces running in parallel
+		fmt.Println(root.Enumerator(tree.RoundedEnumerator))
+		return nil
+	})
+}
+
+var _ clio.FlagAdder = (*modulesUpgradeOptions)(nil)
+
+type modulesUpgradeOptions struct {
+	To         string `mapstructure:"to"`
+	Prerelease bool   `mapstructure:"prerelease"`
+}
+
+func (o *modulesUpgradeOptions) AddFlags(flags clio.FlagSet) {
+	flags.StringVarP(&o.To, "to", "", "Version to upgrade the module to - by default, its latest stable semver tag")
+	flags.BoolVarP(&o.Prerelease, "prerelease", "", "Upgrade to the latest semver tag, even if it is a prerelease - such as v1.2.3-rc.1")
+}
+
+func modulesUpgradeCommand() (*cobra.Command, *modulesUpgradeOptions) {
+	opts := &modulesUpgradeOptions{}
+	return &cobra.Command{
+		Use:   "upgrade [module]",
+		Short: "Upgrade the version of the modules referenced in the blueprints",
+		Long: `Upgrade the version of the modules referenced in the blueprints.
+
+Rewrite the moduleRef fields of the bricks - and the module aliases of the workspace config -
+in the files of the .mason directories, preserving their comments, formatting and anchors.
+The module is either an alias or a module reference, with or without its version.
+Without module, all the modules are upgraded to their latest version.
+The latest version is the highest stable semver tag of the module git repository,
+preferring the tags prefixed by the module path in the repository - such as "golang/v1.2.3".
+With --prerelease, the prerelease tags - such as "v1.2.3-rc.1" - are considered too.
+Run "mason lock" afterward to pin the new versions, if you use a lock file.`,
+		Example: `  # Upgrade all the modules to their latest version
+  mason modules upgrade
+
+  # Upgrade the module with the golang alias to a specific version
+  mason modules upgrade golang --to v0.0.6`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return upgradeModules(args, opts)
+		},
+	}, opts
+}
+
+func upgradeModules(args []string, opts *modulesUpgradeOptions) error {
+	ui.reserveStdout()
+
+	var module string
+	if len(args) > 0 {
+		module = args[0]
+	}
+	if module == "" && opts.To != "" {
+		return errors.New("the --to flag requires a module")
+	}
+	version := func(moduleRef masonry.ModuleRef) (string, error) {
+		return masonry.LatestModuleVersion(moduleRef, opts.Prerelease)
+	}
+	if opts.To != "" {
+		version = func(masonry.ModuleRef) (string, error) {
+			return opts.To, nil
+		}
+	}
+
+	workspaces, err := mason.DetectWorkspaces()
+	if err != nil {
+		return err
+	}
+	if len(workspaces) == 0 {
+		return fmt.Errorf("no .mason directory found")
+	}
+
+	return mason.ForEachWorkspace(workspaces, func(workspace masonry.Workspace) error {
+		updates, err := workspace.UpgradeModules(module, version)
+		if err != nil {
+			return err
+		}
+
+		root := tree.Root("Workspace " + workspace.RelativePath)
+		for _, update := range updates {
+			root.Child(fmt.Sprintf("%s:%d - %s → %s", update.File, update.Line, update.From, update.To))
+		}
+		if len(updates) == 0 {
+			root.Child("No module to upgrade")
+		} else if len(workspace.Lock.Modules) > 0 {
+			root.Child("Run mason lock to pin the new versions")
+		}
+		// print each workspace at once, to avoid mixing the output of workspaces running in parallel
+		fmt.Println(root.Enumerator(tree.RoundedEnumerator))
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vbehar/mason/pkg/masonry"
+)
+
+func planCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "plan [phases]",
+		Short: "Render the plan of the given phases, and print the Dagger scripts without running them",
+		Long: `Render the plan of the given phases - or aliases - for each workspace,
+and print the generated Dagger scripts: the merged script, and the post-run scripts.
+
+The plan is rendered by the modules exactly as it would be for a run,
+but the scripts are not applied.`,
+		Example: `  # Print the scripts of the package phase
+  mason plan package
+
+  # Print the scripts of an alias, for a subset of the bricks
+  mason plan ci -l os=linux`,
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: phasesValidArgsFunction,
+		RunE:              printPlans,
+	}
+}
+
+func printPlans(_ *cobra.Command, args []string) error {
+	ui.reserveStdout()
+	return forEachPlan(args, func(workspace masonry.Workspace, plan *masonry.Plan) error {
+		var output strings.Builder
+		for _, script := range []struct {
+			title   string
+			content string
+		}{
+			{title: "main", content: plan.MergedScript},
+			{title: "post-run on success", content: plan.PostRunOnSuccessScript},
+			{title: "post-run on failure", content: plan.PostRunOnFailureScript},
+		} {
+			if script.content == "" {
+				continue
+			}
+			fmt.Fprintf(&output, "# ==> workspace: %s, phase: %s, script: %s\n", workspace.RelativePath, plan.Phase, script.title)
+			fmt.Fprintf(&output, "%s\n\n", strings.TrimSpace(script.content))
+		}
+		// print each plan at once, to avoid mixing the output of workspaces running in parallel
+		fmt.Print(output.String())
+		return nil
+	})
+}
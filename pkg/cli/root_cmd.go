@@ -56,6 +56,14 @@ func run(cmd *cobra.Command, args []string) error {
 		return cmd.Help()
 	}
 
+	return forEachPlan(args, func(_ masonry.Workspace, plan *masonry.Plan) error {
+		return plan.Run()
+	})
+}
+
+// forEachPlan renders the plans of the given phases for all the (affected) workspaces,
+// and calls fn with each non-empty plan.
+func forEachPlan(phasesOrAliases []string, fn func(masonry.Workspace, *masonry.Plan) error) error {
 	workspaces, err := mason.DetectWorkspaces()
 	if err != nil {
 		return err
@@ -63,26 +71,57 @@ func run(cmd *cobra.Command, args []string) error {
 	if len(workspaces) == 0 {
 		return fmt.Errorf("no .mason directory found")
 	}
-	if len(workspaces) > 1 {
-		return fmt.Errorf("found %d workspaces: %v. Multi-workspace support is not implemented yet. mason ignored-dirs: %v", len(workspaces), workspaces, mason.IgnoredDirs)
+
+	var (
+		changes            *masonry.ChangeSet
+		affectedWorkspaces map[string]bool
+	)
+	if masonConfig.Since != "" {
+		changes, err = mason.DetectChanges(masonConfig.Since)
+		if err != nil {
+			return err
+		}
+		affectedWorkspaces = changes.AffectedWorkspaces(workspaces)
 	}
 
-	workspace := workspaces[0]
-	if workspace.RelativePath != "." {
-		return fmt.Errorf("the .mason directory must be in the current working directory")
+	phases := parsePhasesAndSelectors(phasesOrAliases)
+	mason.Phases = nil
+	for _, phaseCfg := range phases {
+		mason.Phases = append(mason.Phases, phaseCfg.Phase)
 	}
+	return mason.ForEachWorkspace(workspaces, func(workspace masonry.Workspace) error {
+		if changes == nil {
+			return forEachWorkspacePlan(workspace, phases, nil, fn)
+		}
+		if !affectedWorkspaces[workspace.RelativePath] {
+			mason.Logger.WithFields("workspace", workspace.RelativePath, "since", changes.Ref).
+				Info("Workspace not affected by the changes, skipping")
+			return nil
+		}
+		// a workspace affected only through its dependencies has no changed files: all its bricks are affected
+		changedFiles := changes.WorkspaceFiles(workspace, workspaces)
+		return forEachWorkspacePlan(workspace, phases, changedFiles, fn)
+	})
+}
 
+func forEachWorkspacePlan(workspace masonry.Workspace, phases []AliasConfig, changedFiles []string, fn func(masonry.Workspace, *masonry.Plan) error) error {
 	blueprint, err := workspace.LoadBlueprint()
 	if err != nil {
 		return err
 	}
+	if len(changedFiles) > 0 {
+		*blueprint = blueprint.FilterByChanges(changedFiles)
+	}
 
-	for _, phaseCfg := range parsePhasesAndSelectors(args) {
+	for _, phaseCfg := range phases {
 		filteredBlueprint := blueprint.Filter(phaseCfg.labelSelector)
 
 		mason.EventBus.Publish(partybus.Event{
-			Type:   masonry.EventTypeRenderPlan,
-			Source: map[string]string{"phase": phaseCfg.Phase},
+			Type: masonry.EventTypeRenderPlan,
+			Source: map[string]string{
+				"workspace": workspace.RelativePath,
+				"phase":     phaseCfg.Phase,
+			},
 		})
 		plan, err := filteredBlueprint.RenderPlan()
 		if err != nil {
@@ -95,11 +134,12 @@ func run(cmd *cobra.Command, args []string) error {
 		}
 
 		if plan.IsEmpty() {
-			mason.Logger.WithFields("phase", phaseCfg.Phase).Warn("No scripts found, skipping phase")
+			mason.Logger.WithFields("workspace", workspace.RelativePath, "phase", phaseCfg.Phase).
+				Warn("No scripts found, skipping phase")
 			continue
 		}
 
-		err = plan.Run()
+		err = fn(workspace, plan)
 		if err != nil {
 			return err
 		}
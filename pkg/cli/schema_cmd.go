@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/anchore/clio"
+	"github.com/spf13/cobra"
+	"github.com/vbehar/mason/pkg/masonry"
+)
+
+var _ clio.FlagAdder = (*schemaOptions)(nil)
+
+type schemaOptions struct {
+	Specs bool `mapstructure:"specs"`
+}
+
+func (o *schemaOptions) AddFlags(flags clio.FlagSet) {
+	flags.BoolVarP(&o.Specs, "specs", "", "Include the spec schemas published by the modules used by the blueprints - rendered with Dagger")
+}
+
+func schemaCommand() (*cobra.Command, *schemaOptions) {
+	opts := &schemaOptions{}
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the blueprint files",
+		Long: `Print the JSON Schema of the documents of the blueprint files: the bricks - kind, moduleRef,
+metadata and spec - the documents including other files, and the documents only holding YAML anchors.
+Use it with an editor - for example with the YAML language server - for validation and completion.
+
+With --specs, the JSON Schemas published by the modules used by the blueprints of all the workspaces
+are applied to the spec of the bricks of their kind. Note that the templated values of the spec
+are validated as they are written, before their rendering.`,
+		Example: `  # Write the schema, to configure the YAML language server
+  mason schema > .mason/schema.json
+
+  # Include the spec schemas of the modules
+  mason schema --specs > .mason/schema.json`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return printSchema(opts)
+		},
+	}, opts
+}
+
+func printSchema(opts *schemaOptions) error {
+	ui.reserveStdout()
+
+	specSchemas := make(masonry.SpecSchemas)
+	if opts.Specs {
+		workspaces, err := mason.DetectWorkspaces()
+		if err != nil {
+			return err
+		}
+		if len(workspaces) == 0 {
+			return fmt.Errorf("no .mason directory found")
+		}
+
+		var (
+			mutex                  sync.Mutex
+			specSchemasByWorkspace = make(map[string]masonry.SpecSchemas)
+		)
+		err = mason.ForEachWorkspace(workspaces, func(workspace masonry.Workspace) error {
+			blueprint, err := workspace.LoadBlueprint()
+			if err != nil {
+				return err
+			}
+			schemas, err := blueprint.LoadSpecSchemas()
+			if err != nil {
+				return err
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			specSchemasByWorkspace[workspace.RelativePath] = schemas
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// the first workspace publishing a schema for a kind wins
+		for i := len(workspaces) - 1; i >= 0; i-- {
+			maps.Copy(specSchemas, specSchemasByWorkspace[workspaces[i].RelativePath])
+		}
+	}
+
+	data, err := json.MarshalIndent(masonry.BlueprintSchema(specSchemas), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schema: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
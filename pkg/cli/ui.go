@@ -3,8 +3,10 @@ package cli
 import (
 	"fmt"
 	"io"
+	"os"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
 	"github.com/vbehar/mason/pkg/masonry"
 	"github.com/wagoodman/go-partybus"
 )
@@ -20,13 +22,11 @@ func (ui *UI) Setup(subscription partybus.Unsubscribable) error {
 func (ui *UI) Handle(event partybus.Event) error {
 	switch event.Type {
 	case masonry.EventTypeRenderPlan:
-		phase := event.Source.(map[string]string)["phase"]
-		ui.print(phaseStyle.Render(phase))
+		ui.printSource(event.Source.(map[string]string))
 		ui.println(descriptionStyle.Render("Rendering plan..."))
 	case masonry.EventTypeApplyPlan:
-		phase := event.Source.(map[string]string)["phase"]
+		ui.printSource(event.Source.(map[string]string))
 		postRun := event.Source.(map[string]string)["postRun"]
-		ui.print(phaseStyle.Render(phase))
 		switch masonry.PostRun(postRun) {
 		case masonry.PostRunOnSuccess:
 			ui.println(postRunOnSuccessStyle.Render("Post run on success..."))
@@ -35,15 +35,29 @@ func (ui *UI) Handle(event partybus.Event) error {
 		default:
 			ui.println(descriptionStyle.Render("Applying plan..."))
 		}
+	case masonry.EventTypeApplyBrick:
+		source := event.Source.(map[string]string)
+		ui.printSource(source)
+		description := fmt.Sprintf("Applying brick %s...", source["brick"])
+		if source["attempts"] != "1" {
+			description = fmt.Sprintf("Applying brick %s (attempt %s/%s)...", source["brick"], source["attempt"], source["attempts"])
+		}
+		ui.println(descriptionStyle.Render(description))
+	case masonry.EventTypeBrickTimeout:
+		source := event.Source.(map[string]string)
+		ui.printSource(source)
+		ui.println(postRunOnFailureStyle.Render(fmt.Sprintf("Brick %s timed out after %s", source["brick"], source["timeout"])))
 	case masonry.EventTypeDaggerOutput:
-		phase := event.Source.(map[string]string)["phase"]
+		ui.printSource(event.Source.(map[string]string))
 		postRun := event.Source.(map[string]string)["postRun"]
-		ui.print(phaseStyle.Render(phase))
-		switch masonry.PostRun(postRun) {
-		case masonry.PostRunOnSuccess:
+		brick := event.Source.(map[string]string)["brick"]
+		switch {
+		case masonry.PostRun(postRun) == masonry.PostRunOnSuccess:
 			ui.println(postRunOnSuccessStyle.Render("Post run on success Dagger output:"))
-		case masonry.PostRunOnFailure:
+		case masonry.PostRun(postRun) == masonry.PostRunOnFailure:
 			ui.println(postRunOnFailureStyle.Render("Post run on error Dagger output:"))
+		case brick != "":
+			ui.println(descriptionStyle.Render(fmt.Sprintf("Dagger output of brick %s:", brick)))
 		default:
 			ui.println(descriptionStyle.Render("Dagger output:"))
 		}
@@ -56,6 +70,21 @@ func (ui *UI) Teardown(force bool) error {
 	return nil
 }
 
+// reserveStdout sends the UI output to stderr instead of stdout,
+// for the commands printing their result on stdout.
+func (ui *UI) reserveStdout() {
+	if ui.Output == os.Stdout {
+		ui.Output = os.Stderr
+	}
+}
+
+func (ui *UI) printSource(source map[string]string) {
+	if workspace := source["workspace"]; workspace != "" && workspace != "." {
+		ui.print(workspaceStyle.Render(workspace))
+	}
+	ui.print(phaseStyle.Render(source["phase"]))
+}
+
 func (ui *UI) print(a ...any) {
 	fmt.Fprint(ui.Output, a...) //nolint:errcheck // don't care
 }
@@ -65,6 +94,9 @@ func (ui *UI) println(a ...any) {
 }
 
 var (
+	workspaceStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.AdaptiveColor{Light: "#874BFD", Dark: "#AD8CFF"}).
+			Margin(0, 1, 0, 0)
 	phaseStyle = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder(), false, true).
 			BorderForeground(lipgloss.Color("#874BFD")).
@@ -84,3 +116,21 @@ var (
 				Margin(0, 1, 0, 0)
 	descriptionStyle = lipgloss.NewStyle()
 )
+
+// newTable returns a borderless table, to print the results of the commands.
+func newTable(headers ...string) *table.Table {
+	return table.New().
+		BorderTop(false).
+		BorderBottom(false).
+		BorderLeft(false).
+		BorderRight(false).
+		BorderColumn(false).
+		BorderHeader(false).
+		Headers(headers...).
+		StyleFunc(func(row, _ int) lipgloss.Style {
+			if row == table.HeaderRow {
+				return lipgloss.NewStyle().Bold(true).PaddingRight(2)
+			}
+			return lipgloss.NewStyle().PaddingRight(2)
+		})
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss/tree"
+	"github.com/spf13/cobra"
+	"github.com/vbehar/mason/pkg/masonry"
+)
+
+func validateBlueprintCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate-blueprint",
+		Short: "Validate the spec of each brick against the JSON Schema published by its module",
+		Long: `Validate the spec of each brick against the JSON Schema published by its module.
+
+Modules can publish a JSON Schema per kind, through an optional "schema" function
+returning a directory with 1 "<kind>.json" file per kind.
+Bricks whose module does not publish a schema for their kind are not validated.`,
+		Example: `  # Validate all the bricks
+  mason validate-blueprint
+
+  # Validate only the bricks matching a label selector
+  mason validate-blueprint -l kind=GoBinary`,
+		Args: cobra.NoArgs,
+		RunE: validateBlueprints,
+	}
+}
+
+func validateBlueprints(_ *cobra.Command, _ []string) error {
+	ui.reserveStdout()
+
+	workspaces, err := mason.DetectWorkspaces()
+	if err != nil {
+		return err
+	}
+	if len(workspaces) == 0 {
+		return fmt.Errorf("no .mason directory found")
+	}
+
+	return mason.ForEachWorkspace(workspaces, func(workspace masonry.Workspace) error {
+		blueprint, err := workspace.LoadBlueprint()
+		if err != nil {
+			return err
+		}
+		*blueprint = blueprint.Filter(masonConfig.labelSelector)
+
+		schemas, err := blueprint.LoadSpecSchemas()
+		if err != nil {
+			return err
+		}
+
+		var errs error
+		root := tree.Root("Workspace " + workspace.RelativePath)
+		for _, validation := range blueprint.ValidateSpecs(schemas) {
+			brick := validation.Brick
+			description := fmt.Sprintf("%s %s - %s", brick.Kind, brick.Metadata.Name, brick.Source)
+			switch {
+			case !validation.HasSchema:
+				root.Child("- " + description + " (no schema)")
+			case validation.Err == nil:
+				root.Child("✔ " + description)
+			default:
+				child := tree.Root("✘ " + description)
+				for _, line := range strings.Split(validation.Err.Error(), "\n") {
+					child.Child(strings.TrimSpace(strings.TrimPrefix(line, "-")))
+				}
+				root.Child(child)
+				errs = errors.Join(errs, fmt.Errorf("%s: invalid spec for %s %s: %w", brick.Source, brick.Kind, brick.Metadata.Name, validation.Err))
+			}
+		}
+		// print each workspace at once, to avoid mixing the output of workspaces running in parallel
+		fmt.Println(root.Enumerator(tree.RoundedEnumerator))
+		return errs
+	})
+}
@@ -2,14 +2,19 @@ package dagger
 
 import (
 	"bytes"
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"os/signal"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/anchore/go-logger"
 	"github.com/charmbracelet/x/ansi"
@@ -21,13 +26,22 @@ type ExecScriptOpts struct {
 	BinaryPath    string
 	Logger        logger.Logger
 	ScriptPath    string
+	Dir           string
 	Env           []string
 	Args          []string
 	Stdout        io.Writer
 	Stderr        io.Writer
 	DisableOutput bool
+	Timeout       time.Duration // if set, Dagger is interrupted after this duration
+	Secrets       []string      // values redacted from the captured stdout and stderr
 }
 
+// redactedSecret replaces the values of the secrets in the outputs.
+const redactedSecret = "***"
+
+// interruptGracePeriod is the time given to Dagger to stop, once interrupted, before killing it.
+const interruptGracePeriod = 10 * time.Second
+
 func ExecScript(opts ExecScriptOpts) error {
 	if opts.ScriptPath == "" {
 		return fmt.Errorf("script path is required")
@@ -42,8 +56,11 @@ func ExecScript(opts ExecScriptOpts) error {
 		outputWriter = &outputBuffer
 	} else if !term.IsTerminal(os.Stderr.Fd()) {
 		// let's just duplicate stderr to our buffer
-		outputWriter = io.MultiWriter(os.Stderr, &outputBuffer)
+		stderr := &redactingWriter{writer: os.Stderr, secrets: opts.Secrets}
+		defer stderr.Flush()
+		outputWriter = io.MultiWriter(stderr, &outputBuffer)
 	} else {
+		// Dagger's TUI scrubs the values of the secrets it reads from its live output
 		// we'll need to set Dagger's stderr to a fake terminal
 		// so that we can capture the content and write it to our buffer
 		primty, tty, err := pty.Open()
@@ -88,24 +105,50 @@ func ExecScript(opts ExecScriptOpts) error {
 	args = append(args, opts.Args...)
 	args = append(args, opts.ScriptPath)
 
-	cmd := exec.Command(opts.BinaryPath, args...)
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, opts.BinaryPath, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = interruptGracePeriod
+	cmd.Dir = opts.Dir
 	cmd.Env = append(cmd.Environ(), opts.Env...)
 
+	stdout := opts.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	// the stdout is only written once Dagger is done, to redact the secrets split between 2 writes
+	var stdoutBuffer bytes.Buffer
 	cmd.Stderr = outputWriter
-	if opts.Stdout != nil {
-		cmd.Stdout = opts.Stdout
+	if len(opts.Secrets) > 0 {
+		cmd.Stdout = &stdoutBuffer
 	} else {
-		cmd.Stdout = os.Stdout
+		cmd.Stdout = stdout
 	}
 
 	runErr := cmd.Run()
 
+	if len(opts.Secrets) > 0 {
+		_, err := io.WriteString(stdout, redactSecrets(stdoutBuffer.String(), opts.Secrets))
+		if err != nil {
+			opts.Logger.Warnf("failed to write Dagger's stdout: %s", err)
+		}
+	}
+
 	// parse/write the stderr output before handling the error
 	// to make sure we don't lose it before returning
+	// the secrets are redacted before splitting the lines, as they can span several lines - such as PEM keys
 	var outputErrLogHandler sync.Once
-	for _, line := range strings.Split(outputBuffer.String(), "\n") {
+	output := redactSecrets(ansi.Strip(outputBuffer.String()), opts.Secrets)
+	for _, line := range strings.Split(output, "\n") {
 		line := strings.TrimSpace(line)
-		line = ansi.Strip(line)
 		if line == "" {
 			continue
 		}
@@ -120,9 +163,88 @@ func ExecScript(opts ExecScriptOpts) error {
 		}
 	}
 
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("failed to execute dagger script %q: timed out after %s: %w", opts.ScriptPath, opts.Timeout, ctx.Err())
+	}
 	if runErr != nil {
 		return fmt.Errorf("failed to execute dagger script %q: %w", opts.ScriptPath, runErr)
 	}
 
 	return nil
 }
+
+// redactingWriter writes the lines to the underlying writer, with the values of the secrets redacted.
+// The last line is only written once complete, or when flushed - and enough trailing text is kept back
+// to redact the secrets spanning several lines, until they are complete.
+type redactingWriter struct {
+	writer  io.Writer
+	secrets []string
+	pending []byte
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if len(w.secrets) == 0 {
+		return w.writer.Write(p)
+	}
+	w.pending = append(w.pending, p...)
+
+	maxLen := 0
+	for _, secret := range w.secrets {
+		maxLen = max(maxLen, len(secret))
+	}
+	// only complete lines, without the last bytes - where a secret might start without being complete yet
+	cut := min(bytes.LastIndexByte(w.pending, '\n')+1, len(w.pending)-maxLen+1)
+	cut = safeCut(string(w.pending), cut, w.secrets)
+	if cut > 0 {
+		_, err := io.WriteString(w.writer, redactSecrets(string(w.pending[:cut]), w.secrets))
+		w.pending = w.pending[cut:]
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes the pending text, if any.
+func (w *redactingWriter) Flush() {
+	if len(w.pending) > 0 {
+		_, _ = io.WriteString(w.writer, redactSecrets(string(w.pending), w.secrets))
+		w.pending = nil
+	}
+}
+
+// safeCut returns the largest position - up to the given one - which doesn't split an occurrence of a secret.
+func safeCut(text string, cut int, secrets []string) int {
+	for moved := true; moved; {
+		moved = false
+		for _, secret := range secrets {
+			for start := max(0, cut-len(secret)+1); start < cut; start++ {
+				if strings.HasPrefix(text[start:], secret) {
+					cut = start
+					moved = true
+					break
+				}
+			}
+		}
+	}
+	return cut
+}
+
+// redactSecrets replaces the values of the secrets in the given text.
+// The longest values are replaced first, in case a secret contains another one.
+func redactSecrets(text string, secrets []string) string {
+	if len(secrets) == 0 {
+		return text
+	}
+	values := slices.Clone(secrets)
+	slices.SortFunc(values, func(a, b string) int {
+		return cmp.Compare(len(b), len(a))
+	})
+	oldnew := make([]string, 0, len(values)*2)
+	for _, value := range values {
+		if value != "" {
+			oldnew = append(oldnew, value, redactedSecret)
+		}
+	}
+	return strings.NewReplacer(oldnew...).Replace(text)
+}
@@ -0,0 +1,221 @@
+package dagger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anchore/go-logger/adapter/discard"
+)
+
+func TestExecScriptTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		command         string
+		timeout         time.Duration
+		expectedTimeout bool
+	}{
+		{
+			name:    "without timeout",
+			command: "true",
+		},
+		{
+			name:    "within the timeout",
+			command: "true",
+			timeout: time.Minute,
+		},
+		{
+			name:            "timed out",
+			command:         "exec sleep 60",
+			timeout:         100 * time.Millisecond,
+			expectedTimeout: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// a fake dagger binary, running the command instead of the script
+			binaryPath := filepath.Join(t.TempDir(), "dagger")
+			err := os.WriteFile(binaryPath, []byte("#!/bin/sh\n"+tt.command+"\n"), 0755)
+			if err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			start := time.Now()
+			err = ExecScript(ExecScriptOpts{
+				BinaryPath:    binaryPath,
+				Logger:        discard.New(),
+				ScriptPath:    "script.dagger",
+				Stdout:        io.Discard,
+				Stderr:        io.Discard,
+				DisableOutput: true,
+				Timeout:       tt.timeout,
+			})
+			if tt.expectedTimeout {
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("expected a timeout error, got %v", err)
+				}
+				if elapsed := time.Since(start); elapsed > interruptGracePeriod {
+					t.Errorf("expected the script to be interrupted, it ran for %s", elapsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestExecScriptSecrets(t *testing.T) {
+	t.Parallel()
+
+	// a fake dagger binary, printing the secrets on both outputs
+	binaryPath := filepath.Join(t.TempDir(), "dagger")
+	err := os.WriteFile(binaryPath, []byte("#!/bin/sh\necho \"token=s3cr3t\"\necho \"login with s3cr3t-admin\" >&2\n"), 0755)
+	if err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = ExecScript(ExecScriptOpts{
+		BinaryPath:    binaryPath,
+		Logger:        discard.New(),
+		ScriptPath:    "script.dagger",
+		Stdout:        &stdout,
+		Stderr:        &stderr,
+		DisableOutput: true,
+		Secrets:       []string{"s3cr3t", "s3cr3t-admin"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "token=***\n"; stdout.String() != expected {
+		t.Errorf("expected stdout %q, got %q", expected, stdout.String())
+	}
+	if expected := "login with ***\n"; stderr.String() != expected {
+		t.Errorf("expected stderr %q, got %q", expected, stderr.String())
+	}
+}
+
+func TestExecScriptMultilineSecrets(t *testing.T) {
+	t.Parallel()
+
+	// a fake dagger binary, printing a multi-line secret on stderr
+	binaryPath := filepath.Join(t.TempDir(), "dagger")
+	err := os.WriteFile(binaryPath, []byte("#!/bin/sh\nprintf 'reading key\\n-----BEGIN KEY-----\\nMIIEv\\n-----END KEY-----\\ndone\\n' >&2\n"), 0755)
+	if err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	var stderr bytes.Buffer
+	err = ExecScript(ExecScriptOpts{
+		BinaryPath:    binaryPath,
+		Logger:        discard.New(),
+		ScriptPath:    "script.dagger",
+		Stdout:        io.Discard,
+		Stderr:        &stderr,
+		DisableOutput: true,
+		Secrets:       []string{"-----BEGIN KEY-----\nMIIEv\n-----END KEY-----\n"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "reading key\n***done\n"; stderr.String() != expected {
+		t.Errorf("expected stderr %q, got %q", expected, stderr.String())
+	}
+}
+
+func TestRedactingWriter(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	writer := &redactingWriter{writer: &output, secrets: []string{"s3cr3t"}}
+	for _, chunk := range []string{"token=s3", "cr3t\nlogin with s3c", "r3t"} {
+		if _, err := writer.Write([]byte(chunk)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if expected := "token=***\n"; output.String() != expected {
+		t.Errorf("expected the complete lines %q, got %q", expected, output.String())
+	}
+	writer.Flush()
+	if expected := "token=***\nlogin with ***"; output.String() != expected {
+		t.Errorf("expected %q once flushed, got %q", expected, output.String())
+	}
+}
+
+func TestRedactingWriterWithMultilineSecret(t *testing.T) {
+	t.Parallel()
+
+	const key = "-----BEGIN KEY-----\nMIIEv\n-----END KEY-----"
+	var output bytes.Buffer
+	writer := &redactingWriter{writer: &output, secrets: []string{key, "s3cr3t"}}
+	for _, chunk := range []string{"loading\nkey: -----BEGIN KEY-----\n", "MIIEv\n", "-----END KEY-----\ntoken s3cr3t\n"} {
+		if _, err := writer.Write([]byte(chunk)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if strings.Contains(output.String(), "MIIEv") {
+			t.Fatalf("expected the key to be redacted, got %q", output.String())
+		}
+	}
+	writer.Flush()
+	if expected := "loading\nkey: ***\ntoken ***\n"; output.String() != expected {
+		t.Errorf("expected %q, got %q", expected, output.String())
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		text     string
+		secrets  []string
+		expected string
+	}{
+		{
+			name:     "no secrets",
+			text:     "token=s3cr3t",
+			expected: "token=s3cr3t",
+		},
+		{
+			name:     "all the occurrences",
+			text:     "s3cr3t and s3cr3t",
+			secrets:  []string{"s3cr3t"},
+			expected: "*** and ***",
+		},
+		{
+			name:     "secret containing another one",
+			text:     "password=s3cr3t-admin",
+			secrets:  []string{"s3cr3t", "s3cr3t-admin"},
+			expected: "password=***",
+		},
+		{
+			name:     "empty secret",
+			text:     "token=s3cr3t",
+			secrets:  []string{""},
+			expected: "token=s3cr3t",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if actual := redactSecrets(tt.text, tt.secrets); actual != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, actual)
+			}
+		})
+	}
+}
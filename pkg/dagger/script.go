@@ -2,11 +2,16 @@ package dagger
 
 import (
 	"regexp"
+	"strings"
 )
 
 var (
 	varDefinitionRegex = regexp.MustCompile(`\s*([a-zA-Z][a-zA-Z0-9_]*)\s*=\s*\$\(`)
 	varUsageRegex      = regexp.MustCompile(`\$([a-zA-Z][a-zA-Z0-9_]*)`)
+
+	definitionStatementRegex = regexp.MustCompile(`^\s*[a-zA-Z][a-zA-Z0-9_]*\s*=\s*\$\(`)
+	// sideEffectRegex matches the calls of the functions with side effects outside of the Dagger engine
+	sideEffectRegex = regexp.MustCompile(`\|\s*(export|publish|terminal|up)(\s|\)|$)`)
 )
 
 type Script string
@@ -31,3 +36,64 @@ func (s Script) extractVariables(r *regexp.Regexp) map[string]struct{} {
 	}
 	return variables
 }
+
+// Definitions returns the statements of the script defining variables - without the other statements,
+// such as the exports or the outputs.
+func (s Script) Definitions() Script {
+	var definitions []string
+	for _, statement := range s.statements() {
+		if definitionStatementRegex.MatchString(statement) {
+			definitions = append(definitions, statement)
+		}
+	}
+	return Script(strings.Join(definitions, "\n"))
+}
+
+// HasSideEffects returns true if the script calls a function with side effects outside of the Dagger engine,
+// such as export or publish. The side effects of the executed commands - such as a git push - can't be detected.
+func (s Script) HasSideEffects() bool {
+	return sideEffectRegex.MatchString(string(s))
+}
+
+// statements returns the statements of the script: its lines, joined when a statement spans several lines -
+// with a trailing backslash, or unclosed parentheses. The comments and empty lines are skipped.
+func (s Script) statements() []string {
+	var (
+		statements []string
+		statement  strings.Builder
+		depth      int
+		quote      rune
+	)
+	for _, line := range strings.Split(string(s), "\n") {
+		if statement.Len() == 0 && (strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#")) {
+			continue
+		}
+		if statement.Len() > 0 {
+			statement.WriteString("\n")
+		}
+		statement.WriteString(line)
+		for _, r := range line {
+			switch {
+			case quote != 0 && r == quote:
+				quote = 0
+			case quote != 0:
+			case r == '"' || r == '\'':
+				quote = r
+			case r == '(':
+				depth++
+			case r == ')':
+				depth--
+			}
+		}
+		if depth > 0 || quote != 0 || strings.HasSuffix(line, "\\") {
+			continue
+		}
+		statements = append(statements, statement.String())
+		statement.Reset()
+		depth = 0
+	}
+	if statement.Len() > 0 {
+		statements = append(statements, statement.String())
+	}
+	return statements
+}
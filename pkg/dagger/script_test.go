@@ -156,3 +156,64 @@ $mason_linux_arm64 | export bin/mason-linux-arm64`,
 		})
 	}
 }
+
+func TestScriptDefinitions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                string
+		script              string
+		expected            string
+		expectedSideEffects bool
+	}{
+		{
+			name:     "empty script",
+			script:   "",
+			expected: "",
+		},
+		{
+			name: "definitions and other statements",
+			script: `#!/usr/bin/env dagger
+# build the binary
+bin=$(container | from golang | file /go/bin/mason)
+$bin | export bin/mason
+ctr=$(container |
+  from alpine |
+  with-file /bin/mason $bin)
+$ctr | with-exec mason,version | stdout`,
+			expected: `bin=$(container | from golang | file /go/bin/mason)
+ctr=$(container |
+  from alpine |
+  with-file /bin/mason $bin)`,
+		},
+		{
+			name: "continued lines and quotes",
+			script: `ctr=$(container | from alpine \
+  | with-exec echo,")" \
+  | with-exec echo,'(')
+.echo`,
+			expected: `ctr=$(container | from alpine \
+  | with-exec echo,")" \
+  | with-exec echo,'(')`,
+		},
+		{
+			name:                "definition with side effects",
+			script:              "ref=$(container | from alpine | publish ttl.sh/alpine)\n$ref | export ref.txt",
+			expected:            "ref=$(container | from alpine | publish ttl.sh/alpine)",
+			expectedSideEffects: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			definitions := Script(tt.script).Definitions()
+			if string(definitions) != tt.expected {
+				t.Errorf("expected definitions:\n%s\ngot:\n%s", tt.expected, definitions)
+			}
+			if sideEffects := definitions.HasSideEffects(); sideEffects != tt.expectedSideEffects {
+				t.Errorf("expected side effects %v, got %v", tt.expectedSideEffects, sideEffects)
+			}
+		})
+	}
+}
@@ -1,13 +1,12 @@
 package masonry
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/anchore/go-logger"
@@ -15,7 +14,6 @@ import (
 	"github.com/gookit/color"
 	"github.com/pborman/indent"
 	"github.com/rs/xid"
-	"github.com/vbehar/mason/pkg/dagger"
 )
 
 type Blueprint struct {
@@ -32,14 +30,7 @@ func (b Blueprint) Filter(selector labels.Selector) Blueprint {
 	b.logger().WithFields("selector", selector.String()).Debug("Filtering blueprint")
 	var filteredBricks []Brick
 	for _, brick := range b.Bricks {
-		brickLabels := labels.Set(maps.Clone(brick.Metadata.Labels))
-		if brickLabels == nil {
-			brickLabels = make(labels.Set)
-		}
-		brickLabels["module"] = string(brick.ModuleRef)
-		brickLabels["kind"] = brick.Kind
-		brickLabels["name"] = brick.Metadata.Name
-		if selector.Matches(brickLabels) {
+		if selector.Matches(brick.selectorLabels()) {
 			b.logger().WithFields("name", brick.Metadata.Name, "kind", brick.Kind).
 				Trace("Brick matches selector")
 			filteredBricks = append(filteredBricks, brick)
@@ -62,14 +53,55 @@ func (b Blueprint) Filter(selector labels.Selector) Blueprint {
 	}
 }
 
+// FilterByChanges keeps only the bricks whose inputs match at least one of the changed files
+// (relative to the workspace directory). Bricks without inputs are always kept.
+func (b Blueprint) FilterByChanges(changedFiles []string) Blueprint {
+	b.logger().WithFields("files", len(changedFiles)).Debug("Filtering blueprint by changed files")
+	if slices.ContainsFunc(changedFiles, func(file string) bool {
+		return strings.HasPrefix(file, MasonDirName+"/")
+	}) {
+		b.logger().Debug("The blueprint itself changed, all bricks are affected")
+		return b
+	}
+
+	var filteredBricks []Brick
+	for _, brick := range b.Bricks {
+		switch {
+		case len(brick.Metadata.Inputs) == 0:
+			b.logger().WithFields("name", brick.Metadata.Name, "kind", brick.Kind).
+				Trace("Brick has no inputs, it won't be filtered")
+		case brick.Metadata.PostRun != "":
+			b.logger().WithFields("name", brick.Metadata.Name, "kind", brick.Kind).
+				Trace("Brick is marked as post-run, it won't be filtered")
+		case matchInputs(brick.Metadata.Inputs, changedFiles):
+			b.logger().WithFields("name", brick.Metadata.Name, "kind", brick.Kind).
+				Trace("Brick inputs changed")
+		default:
+			b.logger().WithFields("name", brick.Metadata.Name, "kind", brick.Kind).
+				Debug("Brick inputs did not change, skipping brick")
+			continue
+		}
+		filteredBricks = append(filteredBricks, brick)
+	}
+	if len(filteredBricks) != len(b.Bricks) {
+		b.logger().WithFields("kept", len(filteredBricks), "discarded", len(b.Bricks)-len(filteredBricks)).
+			Info("Filtered blueprint by changed files")
+	}
+	return Blueprint{
+		Bricks:    filteredBricks,
+		workspace: b.workspace,
+	}
+}
+
 func (b Blueprint) RenderPlan() (*Plan, error) {
 	planName := xid.New().String()
 	b.logger().WithFields("path", filepath.Join(b.workspace.WorkDir(), planName)).
 		Debug("Preparing plan")
 
-	b.logger().WithFields("path", filepath.Join(b.workspace.WorkDir(), planName, BlueprintDirPrefix)).
+	blueprintDir := filepath.Join(b.workspace.WorkDir(), planName, BlueprintDirPrefix)
+	b.logger().WithFields("path", blueprintDir).
 		Trace("Writing blueprint bricks to disk")
-	modulesDirByRef, err := b.dumpBricksToDiskByModule(planName)
+	renderDirsByModule, err := b.dumpBricksToDiskByModule(blueprintDir, b.needsBrickScripts())
 	if err != nil {
 		return nil, fmt.Errorf("failed to dump blueprint to disk: %w", err)
 	}
@@ -82,14 +114,24 @@ func (b Blueprint) RenderPlan() (*Plan, error) {
 
 	daggerScript := "#!/usr/bin/env dagger\n\n"
 	daggerScript += "directory |\n"
-	for moduleRef, moduleDir := range modulesDirByRef {
-		relativeModuleDir, err := filepath.Rel(b.workspace.Dir(), moduleDir)
+	for moduleRef, renderDirs := range renderDirsByModule {
+		pinnedModuleRef, err := b.workspace.pinnedModuleRef(moduleRef)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get relative path of %q: %w", moduleDir, err)
+			return nil, err
+		}
+		for _, renderDir := range renderDirs {
+			relativeRenderDir, err := filepath.Rel(b.workspace.Dir(), renderDir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get relative path of %q: %w", renderDir, err)
+			}
+			// the plan of a module - or of a brick - is written to the same path as its blueprint
+			planPath, err := filepath.Rel(blueprintDir, renderDir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get relative path of %q: %w", renderDir, err)
+			}
+			daggerScript += fmt.Sprintf("with-directory %[1]s $(%[2]s | render-plan %[3]s) |\n",
+				filepath.ToSlash(planPath), pinnedModuleRef, relativeRenderDir)
 		}
-		moduleName := moduleRef.SanitizedName()
-		daggerScript += fmt.Sprintf("with-directory %[1]s $(%[2]s | render-plan %[3]s) |\n",
-			moduleName, moduleRef, relativeModuleDir)
 	}
 	daggerScript += "export " + planDir + "\n"
 
@@ -103,68 +145,64 @@ func (b Blueprint) RenderPlan() (*Plan, error) {
 		return nil, fmt.Errorf("failed to write file %q: %w", daggerScriptFilePath, err)
 	}
 
-	logFilePath := filepath.Join(planDir, "dagger_render-plan.log")
-	logFile, err := os.Create(logFilePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create log file %q: %w", logFilePath, err)
-	}
-	defer func() {
-		err := logFile.Close()
-		if err != nil {
-			b.logger().WithFields("path", logFilePath).
-				Errorf("Failed to close log file: %s", err)
-		}
-	}()
-
 	b.logger().WithFields("script", daggerScriptFilePath).Info("Rendering plan with Dagger")
-	var daggerOutWriter bytes.Buffer
-	execErr := dagger.ExecScript(dagger.ExecScriptOpts{
-		BinaryPath:    b.workspace.mason.DaggerBinary,
-		Logger:        b.logger(),
-		ScriptPath:    daggerScriptFilePath,
-		Env:           b.workspace.mason.DaggerEnv,
-		Args:          b.workspace.mason.DaggerArgs,
-		DisableOutput: b.workspace.mason.DaggerOutputDisabled,
-		Stdout:        &daggerOutWriter,
-		Stderr:        logFile,
-	})
-
-	// parse/write the dagger output before handling the error
-	// to make sure we don't lose it before returning
-	output := strings.TrimSpace(daggerOutWriter.String())
+	output, execErr := b.workspace.execDaggerScript(daggerScriptFilePath, filepath.Join(planDir, "dagger_render-plan.log"))
 	b.logger().Infof("Dagger output:\n%+v\n",
 		color.Success.Sprint(indent.String("  ", output)),
 	)
-
 	if execErr != nil {
 		return nil, fmt.Errorf("failed to render plan: %w", execErr)
 	}
 
 	b.logger().WithFields("path", planDir).Debug("Parsing generated plan")
-	plan, err := ParsePlanFromDir(planDir)
+	plan, err := parsePlanFromDir(planDir, b)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse plan from directory %s: %w", planDir, err)
 	}
-	plan.blueprint = b
 
 	return plan, nil
 }
 
-func (b Blueprint) dumpBricksToDiskByModule(planName string) (modulesDirByRef map[ModuleRef]string, err error) {
-	modulesDirByRef = make(map[ModuleRef]string)
-	for moduleRef, blueprint := range b.splitByModuleRef() {
-		moduleName := moduleRef.SanitizedName()
-		moduleDir := filepath.Join(b.workspace.WorkDir(), planName, BlueprintDirPrefix, moduleName)
-		modulesDirByRef[moduleRef] = moduleDir
+// needsBrickScripts returns true if the scripts of the plan must be traced back to their brick:
+// when a brick depends on another brick of the plan, or has a timeout or retries.
+func (b Blueprint) needsBrickScripts() bool {
+	for _, brick := range b.Bricks {
+		if brick.Metadata.IsIsolated() {
+			return true
+		}
+		ids, err := brickDependencies(brick, b.Bricks, func(string) bool { return true })
+		if err != nil || len(ids) > 0 {
+			return true
+		}
+	}
+	return false
+}
 
-		err = os.MkdirAll(moduleDir, os.ModePerm)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create directory %s: %w", moduleDir, err)
+// dumpBricksToDiskByModule writes the bricks to the directory of their module,
+// and returns the directories to render, by module:
+// the directory of the module, or - if perBrick is true - 1 directory per brick, named after its ID.
+func (b Blueprint) dumpBricksToDiskByModule(blueprintDir string, perBrick bool) (renderDirsByModule map[ModuleRef][]string, err error) {
+	renderDirsByModule = make(map[ModuleRef][]string)
+	for moduleRef, blueprint := range b.splitByModuleRef() {
+		moduleDir := filepath.Join(blueprintDir, moduleRef.SanitizedName())
+		if !perBrick {
+			renderDirsByModule[moduleRef] = []string{moduleDir}
 		}
 
 		for _, brick := range blueprint.Bricks {
+			brickDir := moduleDir
 			brickFileName := strings.ToLower(brick.Kind) + "_" + strings.ToLower(brick.Metadata.Name) + ".json"
-			brickFilePath := filepath.Join(moduleDir, brickFileName)
+			if perBrick {
+				brickDir = filepath.Join(moduleDir, brick.ID())
+				brickFileName = brick.ID() + ".json"
+				renderDirsByModule[moduleRef] = append(renderDirsByModule[moduleRef], brickDir)
+			}
+			err = os.MkdirAll(brickDir, os.ModePerm)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create directory %s: %w", brickDir, err)
+			}
+
+			brickFilePath := filepath.Join(brickDir, brickFileName)
 			brickFile, err := os.Create(brickFilePath)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create file %s: %w", brickFilePath, err)
@@ -182,7 +220,7 @@ func (b Blueprint) dumpBricksToDiskByModule(planName string) (modulesDirByRef ma
 			}
 		}
 	}
-	return modulesDirByRef, nil
+	return renderDirsByModule, nil
 }
 
 func (b Blueprint) splitByModuleRef() map[ModuleRef]Blueprint {
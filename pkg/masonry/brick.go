@@ -1,14 +1,43 @@
 package masonry
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
 type Brick struct {
 	Kind      string        `json:"kind"`
 	ModuleRef ModuleRef     `json:"moduleRef"`
 	Metadata  BrickMetadata `json:"metadata"`
 	Spec      any           `json:"spec"`
+
+	Source BrickSource `json:"-"`
+}
+
+// ID returns the identifier of the brick in its blueprint: its lowercase kind and name.
+func (b Brick) ID() string {
+	return strings.ToLower(b.Kind) + "_" + strings.ToLower(b.Metadata.Name)
 }
 
 func (b Brick) IsValid() bool {
-	return b.Kind != "" && b.ModuleRef != "" && b.Metadata.Name != ""
+	return len(b.MissingFields()) == 0
+}
+
+func (b Brick) MissingFields() []string {
+	var missingFields []string
+	if b.Kind == "" {
+		missingFields = append(missingFields, "kind")
+	}
+	if b.ModuleRef == "" {
+		missingFields = append(missingFields, "moduleRef")
+	}
+	if b.Metadata.Name == "" {
+		missingFields = append(missingFields, "metadata.name")
+	}
+	return missingFields
 }
 
 type BrickMetadata struct {
@@ -16,6 +45,57 @@ type BrickMetadata struct {
 	Labels      map[string]string `json:"labels"`
 	ExtraPhases []string          `json:"extraPhases"`
 	PostRun     PostRun           `json:"postRun"`
+	Inputs      []string          `json:"inputs,omitempty"`
+	DependsOn   []string          `json:"dependsOn,omitempty"` // names of the bricks whose scripts must run before
+	When        Condition         `json:"when,omitempty"`
+	Extends     string            `json:"extends,omitempty"`   // name of the brick it inherits from
+	Abstract    bool              `json:"abstract,omitempty"`  // only used to be extended
+	Timeout     Duration          `json:"timeout,omitempty"`   // of each attempt to run the scripts of the brick
+	Retries     int               `json:"retries,omitempty"`   // number of attempts after the first one
+	Templated   bool              `json:"templated,omitempty"` // its string values are rendered as templates
+}
+
+// IsIsolated returns true if the scripts of the brick must run in their own Dagger invocation,
+// to enforce its timeout and retries.
+func (m BrickMetadata) IsIsolated() bool {
+	return m.Timeout > 0 || m.Retries > 0
+}
+
+func (m BrickMetadata) validateIsolation() error {
+	switch {
+	case m.Retries < 0:
+		return errors.New("metadata.retries must be positive")
+	case m.IsIsolated() && m.PostRun != PostRunNever:
+		return errors.New("metadata.timeout and metadata.retries are not supported for post-run bricks")
+	}
+	return nil
+}
+
+// Duration is a time.Duration, written as a Go duration string - such as "90s" or "1h30m".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid duration %s: must be a string such as \"90s\" or \"1h30m\"", data)
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", value, err)
+	}
+	if duration < 0 {
+		return fmt.Errorf("invalid duration %q: must be positive", value)
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
 }
 
 type PostRun string
@@ -26,3 +106,27 @@ const (
 	PostRunOnFailure PostRun = "on_failure"
 	PostRunNever     PostRun = ""
 )
+
+// BrickSource is where a brick has been loaded from.
+type BrickSource struct {
+	File     string // relative to the .mason directory, or the URL of a remote file
+	Document int    // index of the YAML document in the file, starting at 0
+
+	// IncludeChain is the list of documents which included the file - the closest first.
+	// It is empty for the files of the .mason directory.
+	IncludeChain []string
+
+	// Matrix is the combination of the matrix values the brick was expanded from, if any.
+	Matrix map[string]string
+}
+
+func (s BrickSource) String() string {
+	source := fmt.Sprintf("%s (document %d)", s.File, s.Document)
+	if len(s.Matrix) > 0 {
+		source = fmt.Sprintf("%s (document %d, matrix %s)", s.File, s.Document, matrixString(s.Matrix))
+	}
+	for _, includer := range s.IncludeChain {
+		source += ", included from " + includer
+	}
+	return source
+}
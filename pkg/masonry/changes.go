@@ -0,0 +1,165 @@
+package masonry
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ChangeSet is the set of files which changed since a git reference.
+type ChangeSet struct {
+	Ref   string
+	Files []string // relative to the root path
+}
+
+func (m Mason) DetectChanges(ref string) (*ChangeSet, error) {
+	rootPath, err := filepath.Abs(m.RootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path of %q: %w", m.RootPath, err)
+	}
+	m.Logger.WithFields("dir", rootPath, "ref", ref).Debug("Detecting changed files")
+
+	// changes to the tracked files - committed or not - since the reference
+	changedFiles, err := gitListFiles(rootPath, "diff", "--name-only", "--relative", ref, "--")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files changed since %q: %w", ref, err)
+	}
+	// and the new files, not yet tracked
+	untrackedFiles, err := gitListFiles(rootPath, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked files: %w", err)
+	}
+
+	files := append(changedFiles, untrackedFiles...)
+	slices.Sort(files)
+	files = slices.Compact(files)
+
+	m.Logger.WithFields("ref", ref, "files", len(files)).Info("Detected changed files")
+	return &ChangeSet{
+		Ref:   ref,
+		Files: files,
+	}, nil
+}
+
+func gitListFiles(dir string, args ...string) ([]string, error) {
+	output, err := runGit(dir, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, filepath.ToSlash(line))
+		}
+	}
+	return files, nil
+}
+
+func runGit(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0") // never prompt for credentials
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("failed to run git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// WorkspaceFiles returns the changed files owned by the given workspace, relative to the workspace directory.
+// A file is owned by the deepest workspace containing it.
+func (c ChangeSet) WorkspaceFiles(workspace Workspace, workspaces []Workspace) []string {
+	var files []string
+	for _, file := range c.Files {
+		owner, ok := owningWorkspace(file, workspaces)
+		if !ok || owner.RelativePath != workspace.RelativePath {
+			continue
+		}
+		relativeFile, _ := workspaceRelativeFile(file, owner)
+		files = append(files, relativeFile)
+	}
+	return files
+}
+
+// AffectedWorkspaces returns the relative paths of the workspaces owning at least one changed file,
+// and of all the workspaces depending on them - directly or not.
+func (c ChangeSet) AffectedWorkspaces(workspaces []Workspace) map[string]bool {
+	affected := make(map[string]bool)
+	for _, file := range c.Files {
+		if owner, ok := owningWorkspace(file, workspaces); ok {
+			affected[owner.RelativePath] = true
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, workspace := range workspaces {
+			if affected[workspace.RelativePath] {
+				continue
+			}
+			if slices.ContainsFunc(workspace.Config.DependsOn, func(dependency string) bool {
+				return affected[dependency]
+			}) {
+				affected[workspace.RelativePath] = true
+				changed = true
+			}
+		}
+	}
+	return affected
+}
+
+func owningWorkspace(file string, workspaces []Workspace) (owner Workspace, found bool) {
+	for _, workspace := range workspaces {
+		if _, ok := workspaceRelativeFile(file, workspace); !ok {
+			continue
+		}
+		if !found || len(workspace.RelativePath) > len(owner.RelativePath) || owner.RelativePath == "." {
+			owner, found = workspace, true
+		}
+	}
+	return owner, found
+}
+
+func workspaceRelativeFile(file string, workspace Workspace) (string, bool) {
+	if workspace.RelativePath == "." {
+		return file, true
+	}
+	prefix := filepath.ToSlash(workspace.RelativePath) + "/"
+	if !strings.HasPrefix(file, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(file, prefix), true
+}
+
+// matchInputs returns true if at least one of the files matches the inputs globs.
+// Globs starting with a "!" exclude the matching files.
+func matchInputs(inputs []string, files []string) bool {
+	for _, file := range files {
+		included := false
+		for _, input := range inputs {
+			if pattern, ok := strings.CutPrefix(input, "!"); ok {
+				if doublestar.MatchUnvalidated(pattern, file) {
+					included = false
+				}
+				continue
+			}
+			if doublestar.MatchUnvalidated(input, file) {
+				included = true
+			}
+		}
+		if included {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package masonry
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestChangeSetAffectedWorkspaces(t *testing.T) {
+	t.Parallel()
+
+	workspaces := []Workspace{
+		{RelativePath: "."},
+		{RelativePath: "libs/proto"},
+		{RelativePath: "libs/utils"},
+		{RelativePath: "services/api", Config: WorkspaceConfig{DependsOn: []string{"libs/proto"}}},
+		{RelativePath: "services/worker", Config: WorkspaceConfig{DependsOn: []string{"services/api"}}},
+	}
+
+	tests := []struct {
+		name                  string
+		files                 []string
+		expectedAffected      []string
+		expectedWorkspaceFile map[string][]string
+	}{
+		{
+			name:             "no changes",
+			expectedAffected: []string{},
+		},
+		{
+			name:             "root files",
+			files:            []string{"README.md", "libs/README.md"},
+			expectedAffected: []string{"."},
+			expectedWorkspaceFile: map[string][]string{
+				".": {"README.md", "libs/README.md"},
+			},
+		},
+		{
+			name:             "leaf workspace",
+			files:            []string{"libs/utils/strings.go"},
+			expectedAffected: []string{"libs/utils"},
+			expectedWorkspaceFile: map[string][]string{
+				".":          nil,
+				"libs/utils": {"strings.go"},
+			},
+		},
+		{
+			name:             "transitive dependents",
+			files:            []string{"libs/proto/api.proto", "libs/proto/.mason/proto.yaml"},
+			expectedAffected: []string{"libs/proto", "services/api", "services/worker"},
+			expectedWorkspaceFile: map[string][]string{
+				"libs/proto":   {"api.proto", ".mason/proto.yaml"},
+				"services/api": nil,
+			},
+		},
+		{
+			name:             "prefix is not a parent directory",
+			files:            []string{"libs/protobuf/api.proto"},
+			expectedAffected: []string{"."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			changes := ChangeSet{Files: tt.files}
+			affected := slices.Sorted(maps.Keys(changes.AffectedWorkspaces(workspaces)))
+			if !slices.Equal(affected, tt.expectedAffected) {
+				t.Errorf("expected affected workspaces %v, got %v", tt.expectedAffected, affected)
+			}
+
+			for _, workspace := range workspaces {
+				expectedFiles, ok := tt.expectedWorkspaceFile[workspace.RelativePath]
+				if !ok {
+					continue
+				}
+				files := changes.WorkspaceFiles(workspace, workspaces)
+				if !slices.Equal(files, expectedFiles) {
+					t.Errorf("expected files %v for workspace %q, got %v", expectedFiles, workspace.RelativePath, files)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchInputs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		inputs   []string
+		files    []string
+		expected bool
+	}{
+		{
+			name:     "no files",
+			inputs:   []string{"**/*.go"},
+			expected: false,
+		},
+		{
+			name:     "matching file",
+			inputs:   []string{"go.mod", "**/*.go"},
+			files:    []string{"README.md", "pkg/cli/app.go"},
+			expected: true,
+		},
+		{
+			name:     "no matching file",
+			inputs:   []string{"go.mod", "**/*.go"},
+			files:    []string{"README.md", ".mason/go-lint.yaml"},
+			expected: false,
+		},
+		{
+			name:     "excluded file",
+			inputs:   []string{"**/*.go", "!**/*_test.go"},
+			files:    []string{"pkg/cli/app_test.go"},
+			expected: false,
+		},
+		{
+			name:     "excluded and included files",
+			inputs:   []string{"**/*.go", "!**/*_test.go"},
+			files:    []string{"pkg/cli/app_test.go", "pkg/cli/app.go"},
+			expected: true,
+		},
+		{
+			name:     "re-included file",
+			inputs:   []string{"**/*.go", "!pkg/**", "pkg/cli/*.go"},
+			files:    []string{"pkg/cli/app.go"},
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			actual := matchInputs(tt.inputs, tt.files)
+			if actual != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
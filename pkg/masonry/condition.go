@@ -0,0 +1,113 @@
+package masonry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// conditionPath is the path of the condition in the fields of a brick.
+const conditionPath = "metadata.when"
+
+// Condition is a Go template expression - with or without the surrounding braces -
+// evaluated against the TemplateData when the blueprint is loaded.
+// The brick is excluded if it evaluates to false. Missing values evaluate to their zero value.
+type Condition string
+
+func (c *Condition) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*c = Condition(strconv.FormatBool(value))
+		return nil
+	}
+	return json.Unmarshal(data, (*string)(c))
+}
+
+// Evaluate returns the result of the condition. An empty condition is true.
+func (c Condition) Evaluate(data TemplateData) (bool, error) {
+	text := strings.TrimSpace(string(c))
+	if text == "" {
+		return true, nil
+	}
+	if !strings.Contains(text, "{{") {
+		text = "{{ " + text + " }}"
+	}
+
+	tmpl, err := template.New(conditionPath).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return false, fmt.Errorf("invalid condition %q: %w", c, err)
+	}
+	var sb strings.Builder
+	err = tmpl.Execute(&sb, data)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate condition %q: %w", c, err)
+	}
+	result, err := strconv.ParseBool(strings.TrimSpace(sb.String()))
+	if err != nil {
+		return false, fmt.Errorf("condition %q must evaluate to true or false, got %q", c, sb.String())
+	}
+	return result, nil
+}
+
+// condition returns the condition of the brick defined by the document.
+func (d document) condition() (Condition, error) {
+	metadata, _ := d.Fields["metadata"].(map[string]any)
+	switch when := metadata["when"].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return Condition(when), nil
+	case bool:
+		return Condition(strconv.FormatBool(when)), nil
+	default:
+		return "", fmt.Errorf("invalid %s: must be a string or a boolean", conditionPath)
+	}
+}
+
+// applyConditions excludes the documents defining a brick whose condition is false.
+// It also returns the lowercase names of the excluded bricks.
+func (w Workspace) applyConditions(documents []document) ([]document, map[string]bool, error) {
+	var (
+		data          TemplateData
+		errs          error
+		included      []document
+		excludedNames = make(map[string]bool)
+	)
+	for _, document := range documents {
+		condition, err := document.condition()
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", document.Source, err))
+			continue
+		}
+		if !document.isBrick() || condition == "" {
+			included = append(included, document)
+			continue
+		}
+		if data == nil {
+			data = w.templateData()
+		}
+
+		ok, err := condition.Evaluate(data.forDocument(document))
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", document.Source, err))
+			continue
+		}
+		if !ok {
+			key, _ := document.brickKey()
+			w.logger().WithFields("brick", key, "source", document.Source.String(), "when", string(condition)).
+				Info("Excluding brick: its condition is false")
+			if name, ok := document.brickName(); ok {
+				excludedNames[strings.ToLower(name)] = true
+			}
+			continue
+		}
+		included = append(included, document)
+	}
+	if errs != nil {
+		return nil, nil, fmt.Errorf("invalid blueprint: %w", errs)
+	}
+	return included, excludedNames, nil
+}
@@ -0,0 +1,161 @@
+package masonry
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestConditionEvaluate(t *testing.T) {
+	t.Parallel()
+
+	data := TemplateData{
+		"env":     map[string]string{"DEPLOY": "true"},
+		"vars":    map[string]string{"version": "1.2.3"},
+		"git":     map[string]string{"branch": "main", "sha": "4b825dc642cb6eb9a060e54bf8d69288fbee4904"},
+		"host":    map[string]string{"os": "linux", "arch": "amd64"},
+		"ci":      true,
+		"profile": "release",
+		"phases":  map[string]bool{"package": true},
+	}
+
+	tests := []struct {
+		condition     Condition
+		expected      bool
+		expectedError string
+	}{
+		{condition: "", expected: true},
+		{condition: "true", expected: true},
+		{condition: "false", expected: false},
+		{condition: `eq .git.branch "main"`, expected: true},
+		{condition: `{{ eq .git.branch "develop" }}`, expected: false},
+		{condition: `.git.tag`, expectedError: `must evaluate to true or false, got ""`},
+		{condition: `ne .git.tag ""`, expected: false},
+		{condition: `and .ci (eq .host.os "linux")`, expected: true},
+		{condition: `.phases.package`, expected: true},
+		{condition: `.phases.publish`, expected: false},
+		{condition: `or .phases.publish (eq .profile "release")`, expected: true},
+		{condition: `eq .env.DEPLOY "true"`, expected: true},
+		{condition: `eq .env.MISSING ""`, expected: true},
+		{condition: `{{ if .ci }}true{{ else }}false{{ end }}`, expected: true},
+		{condition: `eq .git.branch`, expectedError: `failed to evaluate condition`},
+		{condition: `{{ eq .git.branch "main"`, expectedError: `invalid condition`},
+		{condition: `.vars.version`, expectedError: `must evaluate to true or false, got "1.2.3"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.condition), func(t *testing.T) {
+			t.Parallel()
+			actual, err := tt.condition.Evaluate(data)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWorkspaceLoadBlueprintWithConditions(t *testing.T) {
+	t.Parallel()
+
+	rootPath := t.TempDir()
+	masonDir := filepath.Join(rootPath, MasonDirName)
+	if err := os.MkdirAll(masonDir, os.ModePerm); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	writeFile(t, filepath.Join(masonDir, "bricks.yaml"), `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+  when: true
+  dependsOn: [github] # excluded by its condition
+---
+kind: Release
+moduleRef: release
+metadata:
+  name: github
+  when: '{{ and .phases.publish (ne .git.tag "") }}'
+---
+kind: Docs
+moduleRef: docs
+metadata:
+  name: docs
+  when: eq .vars.docs "enabled"
+  templated: true
+  labels:
+    version: "{{ .vars.version }}"
+---
+kind: Docs
+moduleRef: docs
+metadata:
+  name: docs
+  when: ne .vars.docs "enabled"
+`)
+
+	mason := NewMason()
+	mason.Phases = []string{"package"}
+	mason.Variables = map[string]string{"docs": "enabled", "version": "1.2.3"}
+	workspace := Workspace{
+		RootPath:     rootPath,
+		RelativePath: ".",
+		mason:        mason,
+	}
+
+	blueprint, err := workspace.LoadBlueprint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var bricks []string
+	for _, brick := range blueprint.Bricks {
+		bricks = append(bricks, brick.Kind+"/"+brick.Metadata.Name+" - "+brick.Source.String())
+	}
+	expectedBricks := []string{"GoBinary/linux - bricks.yaml (document 0)", "Docs/docs - bricks.yaml (document 2)"}
+	if !slices.Equal(bricks, expectedBricks) {
+		t.Errorf("expected bricks %v, got %v", expectedBricks, bricks)
+	}
+	if when := blueprint.Bricks[0].Metadata.When; when != "true" {
+		t.Errorf("expected condition true, got %q", when)
+	}
+	if version := blueprint.Bricks[1].Metadata.Labels["version"]; version != "1.2.3" {
+		t.Errorf("expected rendered version label 1.2.3, got %q", version)
+	}
+
+	blueprint, err = workspace.LoadBlueprintWithOpts(LoadBlueprintOpts{SkipConditions: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bricks = nil
+	for _, brick := range blueprint.Bricks {
+		bricks = append(bricks, brick.Kind+"/"+brick.Metadata.Name+" - "+brick.Source.String())
+	}
+	expectedBricks = []string{
+		"GoBinary/linux - bricks.yaml (document 0)",
+		"Release/github - bricks.yaml (document 1)",
+		"Docs/docs - bricks.yaml (document 2)",
+		"Docs/docs - bricks.yaml (document 3)",
+	}
+	if !slices.Equal(bricks, expectedBricks) {
+		t.Errorf("expected bricks without conditions %v, got %v", expectedBricks, bricks)
+	}
+
+	writeFile(t, filepath.Join(masonDir, "invalid.yaml"), `kind: Docs
+moduleRef: docs
+metadata:
+  name: invalid
+  when: .vars.docs
+`)
+	_, err = workspace.LoadBlueprint()
+	if err == nil || !strings.Contains(err.Error(), `invalid.yaml (document 0): condition ".vars.docs" must evaluate to true or false, got "enabled"`) {
+		t.Errorf("expected an invalid condition error, got %v", err)
+	}
+}
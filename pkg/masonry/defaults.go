@@ -0,0 +1,33 @@
+package masonry
+
+import (
+	"fmt"
+	"maps"
+)
+
+// applySpecDefaults merges the spec of each brick into the default spec of its kind,
+// with the same rules as the profiles overlays: a null value removes a default field.
+func (w Workspace) applySpecDefaults(documents []document) ([]document, error) {
+	if len(w.Config.SpecDefaults) == 0 {
+		return documents, nil
+	}
+	for i, document := range documents {
+		kind, _ := document.Fields["kind"].(string)
+		defaults, ok := w.Config.SpecDefaultsForKind(kind)
+		if !ok || !document.isBrick() {
+			continue
+		}
+		spec, ok := document.Fields["spec"]
+		if !ok || spec == nil {
+			spec = map[string]any{}
+		}
+		spec, err := mergePatch(defaults, spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to apply the spec defaults of kind %s: %w", document.Source, kind, err)
+		}
+		fields := maps.Clone(document.Fields)
+		fields["spec"] = spec
+		documents[i].Fields = fields
+	}
+	return documents, nil
+}
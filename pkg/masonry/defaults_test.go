@@ -0,0 +1,91 @@
+package masonry
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWorkspaceLoadBlueprintWithModulesAndSpecDefaults(t *testing.T) {
+	t.Parallel()
+
+	config := `modules:
+  golang: github.com/vbehar/mason-modules/golang@v0.0.5
+specDefaults:
+  gobinary:
+    cgo: false
+    sources:
+      include: ["**/*.go"]
+      exclude: [.history]
+    buildArgs: [-ldflags, '-X "main.version={{ .vars.version }}"']
+variables:
+  version: 1.2.3
+`
+	blueprint := `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+  templated: true
+spec:
+  os: linux
+  sources:
+    exclude: [vendor]
+---
+kind: GoBinary
+moduleRef: golang
+metadata:
+  name: darwin
+  templated: true
+---
+kind: GoBinary
+moduleRef: golang
+metadata:
+  name: cgo
+spec:
+  cgo: true
+  buildArgs: null
+---
+kind: GoTest
+moduleRef: github.com/vbehar/mason-modules/golang@v0.0.4
+metadata:
+  name: unit
+spec:
+  race: true
+`
+
+	rootPath := t.TempDir()
+	writeFile(t, filepath.Join(rootPath, MasonDirName, WorkspaceConfigFileName), config)
+	writeFile(t, filepath.Join(rootPath, MasonDirName, "bricks.yaml"), blueprint)
+
+	workspace := Workspace{
+		RootPath:     rootPath,
+		RelativePath: ".",
+		mason:        NewMason(),
+	}
+	workspaceConfig, err := workspace.LoadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	workspace.Config = *workspaceConfig
+
+	loadedBlueprint, err := workspace.LoadBlueprint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var bricks []string
+	for _, brick := range loadedBlueprint.Bricks {
+		bricks = append(bricks, fmt.Sprintf("%s/%s %s: %v", brick.Kind, brick.Metadata.Name, brick.ModuleRef, brick.Spec))
+	}
+	expectedBricks := []string{
+		`GoBinary/linux github.com/vbehar/mason-modules/golang@v0.0.5: map[buildArgs:[-ldflags -X "main.version=1.2.3"] cgo:false os:linux sources:map[exclude:[vendor] include:[**/*.go]]]`,
+		`GoBinary/darwin github.com/vbehar/mason-modules/golang@v0.0.5: map[buildArgs:[-ldflags -X "main.version=1.2.3"] cgo:false sources:map[exclude:[.history] include:[**/*.go]]]`,
+		`GoBinary/cgo github.com/vbehar/mason-modules/golang@v0.0.5: map[cgo:true sources:map[exclude:[.history] include:[**/*.go]]]`,
+		`GoTest/unit github.com/vbehar/mason-modules/golang@v0.0.4: map[race:true]`,
+	}
+	if !slices.Equal(bricks, expectedBricks) {
+		t.Errorf("expected bricks\n%s\ngot\n%s", strings.Join(expectedBricks, "\n"), strings.Join(bricks, "\n"))
+	}
+}
@@ -0,0 +1,87 @@
+package masonry
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// brickDependencies returns the IDs of the bricks the given brick depends on, by name.
+// A name can match bricks of different kinds. A name matching no brick is an error,
+// unless skipUnknown returns true for it - for example when the brick has been filtered out by a selector.
+func brickDependencies(brick Brick, bricks []Brick, skipUnknown func(name string) bool) ([]string, error) {
+	var ids []string
+	for _, name := range brick.Metadata.DependsOn {
+		found := false
+		for _, dependency := range bricks {
+			if !strings.EqualFold(dependency.Metadata.Name, name) {
+				continue
+			}
+			found = true
+			if dependency.ID() != brick.ID() && !slices.Contains(ids, dependency.ID()) {
+				ids = append(ids, dependency.ID())
+			}
+		}
+		if !found && !skipUnknown(name) {
+			return nil, fmt.Errorf("unknown brick %q in metadata.dependsOn", name)
+		}
+		if strings.EqualFold(name, brick.Metadata.Name) {
+			return nil, errors.New("a brick can't depend on itself")
+		}
+	}
+	return ids, nil
+}
+
+// checkBrickDependencies checks that the bricks only depend on existing bricks, without cycles.
+// The dependencies on the bricks excluded by their condition - by lowercase name - are ignored.
+func checkBrickDependencies(bricks []Brick, excludedNames map[string]bool) error {
+	var errs error
+	dependencies := make(map[string][]string) // brick ID -> IDs of its dependencies
+	names := make(map[string]string)          // brick ID -> name
+	for _, brick := range bricks {
+		ids, err := brickDependencies(brick, bricks, func(name string) bool {
+			return excludedNames[strings.ToLower(name)]
+		})
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: invalid brick: %w", brick.Source, err))
+			continue
+		}
+		dependencies[brick.ID()] = ids
+		names[brick.ID()] = brick.Metadata.Name
+	}
+	if errs != nil {
+		return errs
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	states := make(map[string]int)
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		path = append(path, names[id])
+		switch states[id] {
+		case visiting:
+			start := slices.Index(path, names[id])
+			return fmt.Errorf("dependency cycle between bricks: %s", strings.Join(path[start:], " → "))
+		case visited:
+			return nil
+		}
+		states[id] = visiting
+		for _, dependency := range dependencies[id] {
+			if err := visit(dependency, path); err != nil {
+				return err
+			}
+		}
+		states[id] = visited
+		return nil
+	}
+	for _, brick := range bricks {
+		if err := visit(brick.ID(), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
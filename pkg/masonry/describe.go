@@ -0,0 +1,58 @@
+package masonry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// BrickDescription is a brick after all the processing of its blueprint,
+// with the scripts rendered by its module.
+type BrickDescription struct {
+	Brick   Brick
+	JSON    string   // the brick, as sent to its module
+	Scripts []Script // as parsed by ScriptFromFile
+}
+
+// FindBricks returns the bricks with the given name - case-insensitively.
+// The name can be prefixed by the kind of the brick, as "<kind>/<name>".
+func (b Blueprint) FindBricks(name string) []Brick {
+	kind, name, ok := strings.Cut(name, "/")
+	if !ok {
+		kind, name = "", kind
+	}
+	var bricks []Brick
+	for _, brick := range b.Bricks {
+		if !strings.EqualFold(brick.Metadata.Name, name) {
+			continue
+		}
+		if kind != "" && !strings.EqualFold(brick.Kind, kind) {
+			continue
+		}
+		bricks = append(bricks, brick)
+	}
+	return bricks
+}
+
+// DescribeBrick renders the plan of the given brick on its own - without its dependencies -
+// to describe the scripts its module produces for it.
+func (b Blueprint) DescribeBrick(brick Brick) (*BrickDescription, error) {
+	data, err := json.MarshalIndent(brick, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode brick %s %s: %w", brick.Kind, brick.Metadata.Name, err)
+	}
+
+	plan, err := Blueprint{
+		Bricks:    []Brick{brick},
+		workspace: b.workspace,
+	}.RenderPlan()
+	if err != nil {
+		return nil, err
+	}
+
+	return &BrickDescription{
+		Brick:   brick,
+		JSON:    string(data),
+		Scripts: plan.SourceScripts,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package masonry
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBlueprintFindBricks(t *testing.T) {
+	t.Parallel()
+
+	blueprint := Blueprint{
+		Bricks: []Brick{
+			{Kind: "GoBinary", Metadata: BrickMetadata{Name: "mason"}},
+			{Kind: "GoTest", Metadata: BrickMetadata{Name: "mason"}},
+			{Kind: "GoLint", Metadata: BrickMetadata{Name: "lint"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{
+			name:     "by name",
+			query:    "mason",
+			expected: []string{"gobinary_mason", "gotest_mason"},
+		},
+		{
+			name:     "by kind and name",
+			query:    "GoTest/mason",
+			expected: []string{"gotest_mason"},
+		},
+		{
+			name:     "case-insensitive",
+			query:    "golint/LINT",
+			expected: []string{"golint_lint"},
+		},
+		{
+			name:  "unknown brick",
+			query: "GoLint/mason",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var actual []string
+			for _, brick := range blueprint.FindBricks(tt.query) {
+				actual = append(actual, brick.ID())
+			}
+			if !slices.Equal(actual, tt.expected) {
+				t.Errorf("expected bricks %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package masonry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// document is a raw YAML/JSON document from a blueprint file.
+// It may define a brick, or just hold some YAML anchors used by the other documents.
+type document struct {
+	Source BrickSource
+	Fields map[string]any
+}
+
+// loadDocuments loads the documents of all the blueprint files in the .mason directory and its subdirectories,
+// except the work and profiles directories, and the files matching the .masonignore rules.
+// The files included by these files are loaded too.
+func (w Workspace) loadDocuments() ([]document, error) {
+	ignoreRules, err := loadIgnoreRules(filepath.Join(w.MasonDir(), MasonIgnoreFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []blueprintFile
+	err = filepath.WalkDir(w.MasonDir(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(w.MasonDir(), path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path of %s: %w", path, err)
+		}
+		relativePath = filepath.ToSlash(relativePath)
+		if relativePath == "." {
+			return nil
+		}
+
+		if entry.IsDir() {
+			if relativePath == WorkDirPrefix || relativePath == ProfilesDirName || ignoreRules.match(relativePath, true) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if relativePath == WorkspaceConfigFileName {
+			return nil // mason's own config file...
+		}
+
+		switch filepath.Ext(entry.Name()) {
+		case ".json", ".yaml", ".yml":
+			// valid file types
+		default:
+			return nil
+		}
+
+		if ignoreRules.match(relativePath, false) {
+			w.logger().WithFields("file", relativePath).Trace("Ignoring file")
+			return nil
+		}
+
+		files = append(files, w.localBlueprintFile(path))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load blueprint files from %s: %w", w.MasonDir(), err)
+	}
+
+	loader := &documentLoader{
+		workspace: w,
+		loaded:    make(map[string]bool),
+		loading:   make(map[string]bool),
+	}
+	var documents []document
+	for _, file := range files {
+		fileDocuments, err := loader.loadFile(file, nil)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, fileDocuments...)
+	}
+	return documents, nil
+}
+
+// documentLoader loads the documents of blueprint files, and of the files they include.
+type documentLoader struct {
+	workspace Workspace
+	loaded    map[string]bool // keys of the files already loaded - each file is loaded once
+	loading   map[string]bool // keys of the files being loaded - to detect include cycles
+}
+
+// loadFile loads the documents of a blueprint file, replacing the documents with includes
+// by the documents of the included files.
+// The include chain is the list of the documents which included this file - the closest first.
+func (l *documentLoader) loadFile(file blueprintFile, includeChain []string) ([]document, error) {
+	if l.loading[file.key] {
+		return nil, fmt.Errorf("include cycle: %s includes itself, through %s", file.name, strings.Join(includeChain, ", "))
+	}
+	if l.loaded[file.key] {
+		l.workspace.logger().WithFields("file", file.name).Debug("Skipping file already loaded")
+		return nil, nil
+	}
+	l.loaded[file.key] = true
+	l.loading[file.key] = true
+	defer delete(l.loading, file.key)
+
+	l.workspace.logger().WithFields("file", file.name).Trace("Loading file")
+	fileDocuments, err := loadDocumentsFromFile(file.path, file.name)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []document
+	for _, document := range fileDocuments {
+		document.Source.IncludeChain = includeChain
+		if _, ok := document.Fields[includeField]; !ok {
+			documents = append(documents, document)
+			continue
+		}
+
+		includes, err := document.includes()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", document.Source, err)
+		}
+		nestedIncludeChain := append([]string{BrickSource{File: document.Source.File, Document: document.Source.Document}.String()}, includeChain...)
+		for _, include := range includes {
+			includedFile, err := l.workspace.resolveInclude(include, file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: failed to include %s: %w", document.Source, include, err)
+			}
+			includedDocuments, err := l.loadFile(*includedFile, nestedIncludeChain)
+			if err != nil {
+				return nil, err
+			}
+			documents = append(documents, includedDocuments...)
+		}
+	}
+	return documents, nil
+}
+
+func loadDocumentsFromFile(filePath, fileName string) ([]document, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", fileName, err)
+	}
+
+	var documents []document
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for doc := 0; ; doc++ {
+		var fields map[string]any
+		err = decoder.Decode(&fields)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode document %d from file %s: %w", doc, fileName, err)
+		}
+		documents = append(documents, document{
+			Source: BrickSource{
+				File:     fileName,
+				Document: doc,
+			},
+			Fields: fields,
+		})
+	}
+	return documents, nil
+}
+
+// isBrick returns true if the document has at least one of the brick fields.
+// Documents without any of them are only used to hold YAML anchors.
+func (d document) isBrick() bool {
+	for _, field := range []string{"kind", "moduleRef", "metadata", "spec"} {
+		if _, ok := d.Fields[field]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeBrick decodes the document into a brick, through JSON - the format used to send the bricks to the modules.
+// In strict mode, unknown fields are rejected - except in the spec, which is owned by the modules.
+func (d document) decodeBrick(strict bool) (*Brick, error) {
+	data, err := json.Marshal(d.Fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode document: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	brick := Brick{
+		Source: d.Source,
+	}
+	err = decoder.Decode(&brick)
+	if err != nil {
+		return nil, err
+	}
+	return &brick, nil
+}
+
+// decodeBricks decodes the documents defining a brick.
+// In strict mode, invalid bricks are errors, otherwise they are skipped.
+// Bricks with the same kind and name are also invalid:
+// their files would overwrite each other when writing the blueprint for the modules -
+// unless the conditions are skipped and both bricks have one: they might be mutually exclusive.
+func (w Workspace) decodeBricks(documents []document, skipConditions bool) ([]Brick, error) {
+	var (
+		bricks      []Brick
+		errs        error
+		brickByKey  = make(map[string]Brick)
+		strict      = w.mason.StrictBlueprint
+		reportError = func(err error) {
+			if strict {
+				errs = errors.Join(errs, err)
+			} else {
+				w.logger().Warnf("Skipping brick: %s", err)
+			}
+		}
+	)
+	for _, document := range documents {
+		if !document.isBrick() {
+			w.logger().WithFields("file", document.Source.File, "document", document.Source.Document).
+				Trace("Skipping document without brick fields")
+			continue
+		}
+
+		brick, err := document.decodeBrick(strict)
+		if err != nil {
+			reportError(fmt.Errorf("%s: invalid brick: %w", document.Source, err))
+			continue
+		}
+		if missingFields := brick.MissingFields(); len(missingFields) > 0 {
+			reportError(fmt.Errorf("%s: invalid brick: missing %s", document.Source, strings.Join(missingFields, ", ")))
+			continue
+		}
+		if err := brick.Metadata.validateIsolation(); err != nil {
+			reportError(fmt.Errorf("%s: invalid brick: %w", document.Source, err))
+			continue
+		}
+
+		brick.ModuleRef = w.Config.ResolveModuleRef(brick.ModuleRef)
+
+		key := strings.ToLower(brick.Kind) + "/" + strings.ToLower(brick.Metadata.Name)
+		if existingBrick, ok := brickByKey[key]; ok && (!skipConditions || brick.Metadata.When == "" || existingBrick.Metadata.When == "") {
+			reportError(fmt.Errorf("%s: duplicate brick %s %s, already defined in %s",
+				brick.Source, brick.Kind, brick.Metadata.Name, existingBrick.Source))
+			continue
+		}
+		brickByKey[key] = *brick
+
+		w.logger().WithFields("name", brick.Metadata.Name, "kind", brick.Kind).
+			Debug("Loaded brick")
+		bricks = append(bricks, *brick)
+	}
+	if errs != nil {
+		return nil, fmt.Errorf("invalid blueprint: %w", errs)
+	}
+	return bricks, nil
+}
@@ -9,4 +9,6 @@ const (
 	EventTypeDaggerError  = partybus.EventType("dagger.error")
 	EventTypeRenderPlan   = partybus.EventType("plan.render")
 	EventTypeApplyPlan    = partybus.EventType("plan.apply")
+	EventTypeApplyBrick   = partybus.EventType("brick.apply")
+	EventTypeBrickTimeout = partybus.EventType("brick.timeout")
 )
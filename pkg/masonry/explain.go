@@ -0,0 +1,69 @@
+package masonry
+
+import (
+	"maps"
+
+	"github.com/coding-hui/common/labels"
+)
+
+// BrickSelection explains whether a brick is kept by a label selector, as Blueprint.Filter does.
+type BrickSelection struct {
+	Brick        Brick
+	Requirements []RequirementMatch
+	Matches      bool // true if the brick matches the selector
+	PostRun      bool // true if the brick is kept only because it is a post-run brick
+}
+
+// Kept returns true if the brick is kept by the selector.
+func (s BrickSelection) Kept() bool {
+	return s.Matches || s.PostRun
+}
+
+// RequirementMatch is a requirement of a label selector, evaluated against the labels of a brick.
+type RequirementMatch struct {
+	Requirement string
+	Key         string
+	Value       string // value of the label of the brick - if it has it
+	HasLabel    bool
+	Matches     bool
+}
+
+// ExplainFilter evaluates each requirement of the selector against each brick,
+// with the same labels as Blueprint.Filter - including the implicit module, kind and name labels.
+func (b Blueprint) ExplainFilter(selector labels.Selector) []BrickSelection {
+	requirements, selectable := selector.Requirements()
+
+	var selections []BrickSelection
+	for _, brick := range b.Bricks {
+		brickLabels := brick.selectorLabels()
+		selection := BrickSelection{
+			Brick:   brick,
+			Matches: selectable && selector.Matches(brickLabels),
+		}
+		for _, requirement := range requirements {
+			selection.Requirements = append(selection.Requirements, RequirementMatch{
+				Requirement: requirement.String(),
+				Key:         requirement.Key(),
+				Value:       brickLabels.Get(requirement.Key()),
+				HasLabel:    brickLabels.Has(requirement.Key()),
+				Matches:     requirement.Matches(brickLabels),
+			})
+		}
+		selection.PostRun = !selection.Matches && brick.Metadata.PostRun != ""
+		selections = append(selections, selection)
+	}
+	return selections
+}
+
+// selectorLabels returns the labels of the brick used by the label selectors:
+// its own labels, and the implicit module, kind and name labels.
+func (b Brick) selectorLabels() labels.Set {
+	brickLabels := labels.Set(maps.Clone(b.Metadata.Labels))
+	if brickLabels == nil {
+		brickLabels = make(labels.Set)
+	}
+	brickLabels["module"] = string(b.ModuleRef)
+	brickLabels["kind"] = b.Kind
+	brickLabels["name"] = b.Metadata.Name
+	return brickLabels
+}
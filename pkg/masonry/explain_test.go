@@ -0,0 +1,114 @@
+package masonry
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/coding-hui/common/labels"
+)
+
+func TestBlueprintExplainFilter(t *testing.T) {
+	t.Parallel()
+
+	blueprint := Blueprint{
+		Bricks: []Brick{
+			{
+				Kind:      "GoBinary",
+				ModuleRef: "golang",
+				Metadata:  BrickMetadata{Name: "mason-linux", Labels: map[string]string{"os": "linux"}},
+			},
+			{
+				Kind:      "GoBinary",
+				ModuleRef: "golang",
+				Metadata:  BrickMetadata{Name: "mason-darwin", Labels: map[string]string{"os": "darwin"}},
+			},
+			{
+				Kind:      "GoTest",
+				ModuleRef: "golang",
+				Metadata:  BrickMetadata{Name: "unit-tests"},
+			},
+			{
+				Kind:      "PipelineDebug",
+				ModuleRef: "llm",
+				Metadata:  BrickMetadata{Name: "debug", PostRun: PostRunOnFailure},
+			},
+		},
+		workspace: Workspace{mason: NewMason()},
+	}
+
+	tests := []struct {
+		name     string
+		selector string
+		expected []BrickSelection
+	}{
+		{
+			name:     "empty selector",
+			selector: "",
+			expected: []BrickSelection{
+				{Matches: true},
+				{Matches: true},
+				{Matches: true},
+				{Matches: true},
+			},
+		},
+		{
+			name:     "implicit and explicit labels",
+			selector: "kind=GoBinary,os notin (darwin)",
+			expected: []BrickSelection{
+				{
+					Matches: true,
+					Requirements: []RequirementMatch{
+						{Requirement: "kind=GoBinary", Key: "kind", Value: "GoBinary", HasLabel: true, Matches: true},
+						{Requirement: "os notin (darwin)", Key: "os", Value: "linux", HasLabel: true, Matches: true},
+					},
+				},
+				{
+					Requirements: []RequirementMatch{
+						{Requirement: "kind=GoBinary", Key: "kind", Value: "GoBinary", HasLabel: true, Matches: true},
+						{Requirement: "os notin (darwin)", Key: "os", Value: "darwin", HasLabel: true, Matches: false},
+					},
+				},
+				{
+					Requirements: []RequirementMatch{
+						{Requirement: "kind=GoBinary", Key: "kind", Value: "GoTest", HasLabel: true, Matches: false},
+						{Requirement: "os notin (darwin)", Key: "os", Matches: true},
+					},
+				},
+				{
+					PostRun: true,
+					Requirements: []RequirementMatch{
+						{Requirement: "kind=GoBinary", Key: "kind", Value: "PipelineDebug", HasLabel: true, Matches: false},
+						{Requirement: "os notin (darwin)", Key: "os", Matches: true},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			selector, err := labels.Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("failed to parse selector: %v", err)
+			}
+			selections := blueprint.ExplainFilter(selector)
+			filtered := blueprint.Filter(selector)
+
+			var kept []Brick
+			for i := range selections {
+				if selections[i].Kept() {
+					kept = append(kept, selections[i].Brick)
+				}
+				selections[i].Brick = Brick{}
+			}
+			if !reflect.DeepEqual(selections, tt.expected) {
+				t.Errorf("expected selections:\n%+v\ngot:\n%+v", tt.expected, selections)
+			}
+			if !reflect.DeepEqual(kept, filtered.Bricks) {
+				t.Errorf("expected the kept bricks to be the filtered bricks %+v, got %+v", filtered.Bricks, kept)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package masonry
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pborman/indent"
+)
+
+// resolveExtends resolves the bricks extending another brick - by name, from any file of the workspace:
+// the kind, moduleRef, labels and spec of the extended brick are inherited, and deep-merged with the brick's own:
+// maps are merged recursively - unless they have the "$patch: replace" directive - other values - including lists -
+// are replaced by the brick's values, and null values remove the inherited fields.
+// The abstract bricks - only used to be extended - are then removed.
+func (w Workspace) resolveExtends(documents []document) ([]document, error) {
+	resolver := extendsResolver{
+		documents: documents,
+		resolved:  make(map[int]map[string]any),
+		byName:    make(map[string][]int),
+	}
+	for i, document := range documents {
+		if name, ok := document.brickName(); ok && document.isBrick() {
+			resolver.byName[strings.ToLower(name)] = append(resolver.byName[strings.ToLower(name)], i)
+		}
+	}
+
+	var (
+		resolved []document
+		errs     error
+	)
+	for i, document := range documents {
+		if !document.isBrick() {
+			resolved = append(resolved, document)
+			continue
+		}
+		fields, err := resolver.resolve(i, nil)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: invalid brick: %w", document.Source, err))
+			continue
+		}
+		if document.isAbstract() {
+			continue
+		}
+		if extends, _ := document.extends(); extends != "" {
+			if content, err := yaml.Marshal(fields); err == nil {
+				w.logger().WithFields("file", document.Source.File, "document", document.Source.Document, "extends", extends).
+					Debugf("Resolved brick:\n%s", indent.String("  ", string(content)))
+			}
+		}
+		document.Fields = fields
+		resolved = append(resolved, document)
+	}
+	if errs != nil {
+		return nil, fmt.Errorf("invalid blueprint: %w", errs)
+	}
+	return resolved, nil
+}
+
+type extendsResolver struct {
+	documents []document
+	resolved  map[int]map[string]any // resolved fields, by document index
+	byName    map[string][]int       // indexes of the documents defining a brick, by lowercase name
+}
+
+// resolve returns the fields of the document, merged with the inherited ones.
+// The chain is the list of the names of the bricks extending this one, to detect cycles.
+func (r extendsResolver) resolve(index int, chain []string) (map[string]any, error) {
+	if fields, ok := r.resolved[index]; ok {
+		return fields, nil
+	}
+	document := r.documents[index]
+	name, _ := document.brickName()
+	extends, err := document.extends()
+	if err != nil || extends == "" {
+		return document.Fields, err
+	}
+
+	chain = append(chain, name)
+	for i, extending := range chain[:len(chain)-1] {
+		if strings.EqualFold(extending, name) {
+			return nil, fmt.Errorf("extends cycle: %s", strings.Join(chain[i:], " → "))
+		}
+	}
+
+	parentIndex, err := r.lookup(extends, index)
+	if err != nil {
+		return nil, err
+	}
+	parentFields, err := r.resolve(parentIndex, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	inherited := make(map[string]any)
+	for _, key := range []string{"kind", "moduleRef", "spec"} {
+		if value, ok := parentFields[key]; ok {
+			inherited[key] = value
+		}
+	}
+	parentMetadata, _ := parentFields["metadata"].(map[string]any)
+	inheritedMetadata := make(map[string]any)
+	for _, key := range []string{"labels", "templated"} {
+		if value, ok := parentMetadata[key]; ok {
+			inheritedMetadata[key] = value
+		}
+	}
+	if len(inheritedMetadata) > 0 {
+		inherited["metadata"] = inheritedMetadata
+	}
+
+	merged, err := mergePatch(inherited, document.Fields)
+	if err != nil {
+		return nil, err
+	}
+	fields, _ := merged.(map[string]any)
+	r.resolved[index] = fields
+	return fields, nil
+}
+
+// lookup returns the index of the document defining the extended brick.
+// If several bricks have the same name, only an abstract one can be extended.
+func (r extendsResolver) lookup(name string, extendingIndex int) (int, error) {
+	var candidates, abstractCandidates []int
+	for _, index := range r.byName[strings.ToLower(name)] {
+		if index == extendingIndex {
+			continue
+		}
+		candidates = append(candidates, index)
+		if r.documents[index].isAbstract() {
+			abstractCandidates = append(abstractCandidates, index)
+		}
+	}
+	switch {
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case len(abstractCandidates) == 1:
+		return abstractCandidates[0], nil
+	case len(candidates) == 0:
+		return 0, fmt.Errorf("unknown brick %q in metadata.extends", name)
+	default:
+		var sources []string
+		for _, index := range candidates {
+			sources = append(sources, r.documents[index].Source.String())
+		}
+		return 0, fmt.Errorf("ambiguous brick %q in metadata.extends, defined in %s", name, strings.Join(sources, ", "))
+	}
+}
+
+func (d document) brickName() (string, bool) {
+	metadata, _ := d.Fields["metadata"].(map[string]any)
+	name, ok := metadata["name"].(string)
+	return name, ok && name != ""
+}
+
+// extends returns the name of the brick extended by the brick defined by the document, if any.
+func (d document) extends() (string, error) {
+	metadata, _ := d.Fields["metadata"].(map[string]any)
+	switch extends := metadata["extends"].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return extends, nil
+	default:
+		return "", errors.New("invalid metadata.extends: must be the name of a brick")
+	}
+}
+
+// isAbstract returns true if the document defines an abstract brick, which can only be extended.
+func (d document) isAbstract() bool {
+	metadata, _ := d.Fields["metadata"].(map[string]any)
+	abstract, _ := metadata["abstract"].(bool)
+	return abstract
+}
@@ -0,0 +1,207 @@
+package masonry
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWorkspaceLoadBlueprintWithExtends(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		files          map[string]string
+		expectedBricks []Brick
+		expectedError  string
+	}{
+		{
+			name: "abstract brick in another file",
+			files: map[string]string{
+				"templates/go.yaml": `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: go-binary
+  abstract: true
+  labels:
+    lang: go
+    os: linux
+  extraPhases: [run]
+spec:
+  cgo: false
+  ldflags: [-s, -w]
+  output:
+    dir: bin
+`,
+				"binaries.yaml": `metadata:
+  name: mason-darwin
+  extends: go-binary
+  labels:
+    os: darwin
+spec:
+  os: darwin
+  ldflags: [-s]
+  output:
+    dir: null
+    file: mason
+`,
+			},
+			expectedBricks: []Brick{
+				{
+					Kind:      "GoBinary",
+					ModuleRef: "golang",
+					Metadata: BrickMetadata{
+						Name:    "mason-darwin",
+						Extends: "go-binary",
+						Labels:  map[string]string{"lang": "go", "os": "darwin"},
+					},
+					Spec: map[string]any{
+						"cgo":     false,
+						"os":      "darwin",
+						"ldflags": []any{"-s"},
+						"output":  map[string]any{"file": "mason"},
+					},
+				},
+			},
+		},
+		{
+			name: "chain of concrete bricks",
+			files: map[string]string{
+				"bricks.yaml": `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+spec:
+  os: linux
+  arch: amd64
+---
+metadata:
+  name: linux-arm64
+  extends: linux
+spec:
+  arch: arm64
+---
+kind: GoTest
+metadata:
+  name: linux-arm64-test
+  extends: linux-arm64
+spec:
+  $patch: replace
+  race: true
+`,
+			},
+			expectedBricks: []Brick{
+				{
+					Kind:      "GoBinary",
+					ModuleRef: "golang",
+					Metadata:  BrickMetadata{Name: "linux"},
+					Spec:      map[string]any{"os": "linux", "arch": "amd64"},
+				},
+				{
+					Kind:      "GoBinary",
+					ModuleRef: "golang",
+					Metadata:  BrickMetadata{Name: "linux-arm64", Extends: "linux"},
+					Spec:      map[string]any{"os": "linux", "arch": "arm64"},
+				},
+				{
+					Kind:      "GoTest",
+					ModuleRef: "golang",
+					Metadata:  BrickMetadata{Name: "linux-arm64-test", Extends: "linux-arm64"},
+					Spec:      map[string]any{"race": true},
+				},
+			},
+		},
+		{
+			name: "unknown brick",
+			files: map[string]string{
+				"bricks.yaml": `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+  extends: go-binary
+`,
+			},
+			expectedError: `bricks.yaml (document 0): invalid brick: unknown brick "go-binary" in metadata.extends`,
+		},
+		{
+			name: "ambiguous brick",
+			files: map[string]string{
+				"bricks.yaml": `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+---
+kind: GoTest
+moduleRef: golang
+metadata:
+  name: linux
+---
+kind: GoLint
+moduleRef: golang
+metadata:
+  name: lint
+  extends: linux
+`,
+			},
+			expectedError: `bricks.yaml (document 2): invalid brick: ambiguous brick "linux" in metadata.extends, defined in bricks.yaml (document 0), bricks.yaml (document 1)`,
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"bricks.yaml": `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: a
+  extends: b
+---
+kind: GoBinary
+moduleRef: golang
+metadata:
+  name: b
+  extends: a
+`,
+			},
+			expectedError: "invalid brick: extends cycle: a → b → a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rootPath := t.TempDir()
+			masonDir := filepath.Join(rootPath, MasonDirName)
+			if err := os.MkdirAll(masonDir, os.ModePerm); err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+			for name, content := range tt.files {
+				writeFile(t, filepath.Join(masonDir, filepath.FromSlash(name)), content)
+			}
+			workspace := Workspace{
+				RootPath:     rootPath,
+				RelativePath: ".",
+				mason:        NewMason(),
+			}
+
+			blueprint, err := workspace.LoadBlueprint()
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for i := range blueprint.Bricks {
+				blueprint.Bricks[i].Source = BrickSource{}
+			}
+			if !reflect.DeepEqual(blueprint.Bricks, tt.expectedBricks) {
+				t.Errorf("expected bricks:\n%+v\ngot:\n%+v", tt.expectedBricks, blueprint.Bricks)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package masonry
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ScriptGraph is the graph of the main scripts of a plan,
+// linked by the variables they define and use.
+type ScriptGraph struct {
+	Workspace string            `json:"workspace"`
+	Phase     string            `json:"phase"`
+	Nodes     []ScriptGraphNode `json:"nodes"`
+	Edges     []ScriptGraphEdge `json:"edges"`
+}
+
+type ScriptGraphNode struct {
+	ID         string `json:"id"`
+	ModuleName string `json:"module"`
+	Phase      string `json:"phase,omitempty"`
+	Name       string `json:"name"`
+}
+
+type ScriptGraphEdge struct {
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Variables []string `json:"variables"`
+	DependsOn bool     `json:"dependsOn,omitempty"` // the brick of the destination depends on the brick of the source
+}
+
+func (p Plan) Graph() (*ScriptGraph, error) {
+	var mainScripts []Script
+	for _, script := range p.SourceScripts {
+		if script.PostRun == "" {
+			mainScripts = append(mainScripts, script)
+		}
+	}
+
+	graph, err := newScriptGraph(mainScripts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build graph for phase %s: %w", p.Phase, err)
+	}
+	graph.Workspace = p.blueprint.workspace.RelativePath
+	graph.Phase = p.Phase
+	return graph, nil
+}
+
+func newScriptGraph(scripts []Script) (*ScriptGraph, error) {
+	scriptsDAG, err := newScriptsDAG(scripts)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &ScriptGraph{
+		Nodes: []ScriptGraphNode{},
+		Edges: []ScriptGraphEdge{},
+	}
+	nodeIDs := make(map[string]string) // DAG vertex ID -> node ID
+	for vertexID, val := range scriptsDAG.GetVertices() {
+		script, ok := val.(*Script)
+		if !ok {
+			return nil, fmt.Errorf("failed to cast vertex %q to Script", vertexID)
+		}
+		graph.Nodes = append(graph.Nodes, ScriptGraphNode{
+			ID:         vertexID, // temporary, replaced once the nodes are sorted
+			ModuleName: script.ModuleName,
+			Phase:      script.Phase,
+			Name:       script.Name,
+		})
+	}
+	slices.SortFunc(graph.Nodes, func(a, b ScriptGraphNode) int {
+		return cmp.Or(
+			cmp.Compare(a.ModuleName, b.ModuleName),
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.Phase, b.Phase),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+	usedIDs := make(map[string]int)
+	for i := range graph.Nodes {
+		node := &graph.Nodes[i]
+		id := node.ModuleName + "/" + node.Name
+		if usedIDs[id]++; usedIDs[id] > 1 {
+			id = fmt.Sprintf("%s#%d", id, usedIDs[id])
+		}
+		nodeIDs[node.ID] = id
+		node.ID = id
+	}
+
+	edges := make(map[[2]string]bool)
+	for edge := range scriptsDAG.edgesVariables {
+		edges[edge] = true
+	}
+	for edge := range scriptsDAG.dependencyEdges {
+		edges[edge] = true
+	}
+	for edge := range edges {
+		variables := slices.Clone(scriptsDAG.edgesVariables[edge])
+		if variables == nil {
+			variables = []string{}
+		}
+		slices.Sort(variables)
+		graph.Edges = append(graph.Edges, ScriptGraphEdge{
+			From:      nodeIDs[edge[0]],
+			To:        nodeIDs[edge[1]],
+			Variables: variables,
+			DependsOn: scriptsDAG.dependencyEdges[edge],
+		})
+	}
+	slices.SortFunc(graph.Edges, func(a, b ScriptGraphEdge) int {
+		return cmp.Or(
+			cmp.Compare(a.From, b.From),
+			cmp.Compare(a.To, b.To),
+		)
+	})
+
+	return graph, nil
+}
+
+// DOT returns the graph in the Graphviz DOT format, with 1 cluster per module.
+func (g ScriptGraph) DOT() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "digraph %q {\n", g.title())
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box];\n")
+	for i, module := range g.modules() {
+		fmt.Fprintf(&sb, "\n  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(&sb, "    label=%q;\n", module)
+		for _, node := range g.Nodes {
+			if node.ModuleName == module {
+				fmt.Fprintf(&sb, "    %q [label=%q];\n", node.ID, node.label())
+			}
+		}
+		sb.WriteString("  }\n")
+	}
+	if len(g.Edges) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&sb, "  %q -> %q [label=%q];\n", edge.From, edge.To, edge.label())
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// Mermaid returns the graph as a Mermaid flowchart, with 1 subgraph per module.
+func (g ScriptGraph) Mermaid() string {
+	mermaidIDs := make(map[string]string, len(g.Nodes))
+	for i, node := range g.Nodes {
+		mermaidIDs[node.ID] = fmt.Sprintf("n%d", i)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "---\ntitle: %q\n---\n", g.title())
+	sb.WriteString("flowchart LR\n")
+	for i, module := range g.modules() {
+		fmt.Fprintf(&sb, "  subgraph m%d[%q]\n", i, module)
+		for _, node := range g.Nodes {
+			if node.ModuleName == module {
+				fmt.Fprintf(&sb, "    %s[%q]\n", mermaidIDs[node.ID], node.label())
+			}
+		}
+		sb.WriteString("  end\n")
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&sb, "  %s -- %q --> %s\n", mermaidIDs[edge.From], edge.label(), mermaidIDs[edge.To])
+	}
+	return sb.String()
+}
+
+func (g ScriptGraph) title() string {
+	return fmt.Sprintf("%s: %s", g.Workspace, g.Phase)
+}
+
+func (g ScriptGraph) modules() []string {
+	var modules []string
+	for _, node := range g.Nodes {
+		if !slices.Contains(modules, node.ModuleName) {
+			modules = append(modules, node.ModuleName)
+		}
+	}
+	return modules
+}
+
+func (n ScriptGraphNode) label() string {
+	if n.Phase == "" {
+		return n.Name
+	}
+	return n.Phase + "_" + n.Name
+}
+
+func (e ScriptGraphEdge) label() string {
+	labels := slices.Clone(e.Variables)
+	if e.DependsOn {
+		labels = append(labels, "dependsOn")
+	}
+	return strings.Join(labels, ", ")
+}
@@ -0,0 +1,138 @@
+package masonry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScriptGraph(t *testing.T) {
+	t.Parallel()
+
+	scripts := []Script{
+		{
+			ModuleName: "golang",
+			Phase:      "package",
+			Name:       "linux_amd64",
+			Content:    "mason_linux_amd64=$(golang | build-binary)\n$mason_linux_amd64 | export bin/mason",
+		},
+		{
+			ModuleName: "run",
+			Phase:      "package",
+			Name:       "version",
+			Content:    "ctr=$(container | with-file /mason $mason_linux_amd64)\n$ctr | with-exec /mason version | stdout",
+		},
+		{
+			ModuleName: "run",
+			Name:       "publish",
+			Content:    "$ctr | publish $REGISTRY",
+		},
+		{
+			ModuleName: "golang",
+			Phase:      "package",
+			Name:       "lint",
+			Content:    "golang | lint",
+		},
+	}
+
+	graph, err := newScriptGraph(scripts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	graph.Workspace = "."
+	graph.Phase = "package"
+
+	expectedDOT := `digraph ".: package" {
+  rankdir=LR;
+  node [shape=box];
+
+  subgraph cluster_0 {
+    label="golang";
+    "golang/lint" [label="package_lint"];
+    "golang/linux_amd64" [label="package_linux_amd64"];
+  }
+
+  subgraph cluster_1 {
+    label="run";
+    "run/publish" [label="publish"];
+    "run/version" [label="package_version"];
+  }
+
+  "golang/linux_amd64" -> "run/version" [label="mason_linux_amd64"];
+  "run/version" -> "run/publish" [label="ctr"];
+}
+`
+	if actual := graph.DOT(); actual != expectedDOT {
+		t.Errorf("expected DOT:\n%s\ngot:\n%s", expectedDOT, actual)
+	}
+
+	expectedMermaid := `---
+title: ".: package"
+---
+flowchart LR
+  subgraph m0["golang"]
+    n0["package_lint"]
+    n1["package_linux_amd64"]
+  end
+  subgraph m1["run"]
+    n2["publish"]
+    n3["package_version"]
+  end
+  n1 -- "mason_linux_amd64" --> n3
+  n3 -- "ctr" --> n2
+`
+	if actual := graph.Mermaid(); actual != expectedMermaid {
+		t.Errorf("expected Mermaid:\n%s\ngot:\n%s", expectedMermaid, actual)
+	}
+}
+
+func TestScriptGraphWithMultipleVariables(t *testing.T) {
+	t.Parallel()
+
+	graph, err := newScriptGraph([]Script{
+		{ModuleName: "a", Name: "define", Content: "foo=$(container)\nbar=$(directory)"},
+		{ModuleName: "b", Name: "use", Content: "$foo | with-directory /src $bar"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(graph.Edges) != 1 {
+		t.Fatalf("expected 1 edge, got %d: %+v", len(graph.Edges), graph.Edges)
+	}
+	if variables := strings.Join(graph.Edges[0].Variables, ","); variables != "bar,foo" {
+		t.Errorf("expected edge variables bar,foo - got %s", variables)
+	}
+}
+
+func TestScriptGraphWithCycle(t *testing.T) {
+	t.Parallel()
+
+	_, err := newScriptGraph([]Script{
+		{ModuleName: "a", Name: "one", Content: "a=$(echo $b)"},
+		{ModuleName: "a", Name: "two", Content: "b=$(echo $a)"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "would create a loop") {
+		t.Fatalf("expected a loop error, got %v", err)
+	}
+}
+
+func TestScriptGraphWithBrickDependencies(t *testing.T) {
+	t.Parallel()
+
+	graph, err := newScriptGraph([]Script{
+		{ModuleName: "golang", Name: "binary", Content: "bin=$(container | from golang)", Brick: "gobinary_linux"},
+		{ModuleName: "docs", Name: "publish", Content: "container | from alpine", Brick: "docs_site", DependsOn: []string{"gobinary_linux"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(graph.Edges) != 1 {
+		t.Fatalf("expected 1 edge, got %d", len(graph.Edges))
+	}
+	edge := graph.Edges[0]
+	if edge.From != "golang/binary" || edge.To != "docs/publish" || !edge.DependsOn || len(edge.Variables) != 0 {
+		t.Errorf("expected a dependency edge from golang/binary to docs/publish, got %+v", edge)
+	}
+	if !strings.Contains(graph.DOT(), `"golang/binary" -> "docs/publish" [label="dependsOn"];`) {
+		t.Errorf("expected the dependency edge in the DOT output, got:\n%s", graph.DOT())
+	}
+}
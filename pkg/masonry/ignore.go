@@ -0,0 +1,85 @@
+package masonry
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ignoreRules are the rules of a .masonignore file, using a subset of the .gitignore syntax:
+//   - empty lines and lines starting with "#" are ignored
+//   - a rule starting with "!" re-includes the matching files
+//   - a rule ending with "/" only matches directories
+//   - a rule without any "/" matches the file or directory name at any depth,
+//     otherwise it matches the path relative to the .mason directory
+//   - globs are supported, including "**" - a rule ending with "/**" matches the content of the directory,
+//     not the directory itself, so that its files can be re-included
+type ignoreRules []ignoreRule
+
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	baseName bool
+}
+
+func loadIgnoreRules(filePath string) (ignoreRules, error) {
+	content, err := os.ReadFile(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignore file %s: %w", filePath, err)
+	}
+	return parseIgnoreRules(content), nil
+}
+
+func parseIgnoreRules(content []byte) ignoreRules {
+	var rules ignoreRules
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		line, rule.negate = strings.CutPrefix(line, "!")
+		line, rule.dirOnly = strings.CutSuffix(line, "/")
+		rule.baseName = !strings.Contains(line, "/")
+		rule.pattern = strings.TrimPrefix(line, "/")
+		if rule.pattern != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// match returns true if the given path - relative to the .mason directory, with forward slashes - is ignored.
+// The last matching rule wins.
+func (r ignoreRules) match(relativePath string, isDir bool) bool {
+	ignored := false
+	for _, rule := range r {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		name := relativePath
+		if rule.baseName {
+			name = path.Base(relativePath)
+		}
+		if dir, ok := strings.CutSuffix(rule.pattern, "/**"); ok && doublestar.MatchUnvalidated(dir, name) {
+			continue
+		}
+		if doublestar.MatchUnvalidated(rule.pattern, name) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,44 @@
+package masonry
+
+import "testing"
+
+func TestIgnoreRulesMatch(t *testing.T) {
+	t.Parallel()
+
+	rules := parseIgnoreRules([]byte(`# comment
+
+*.draft.yaml
+drafts/
+/local.yaml
+bricks/experimental/**
+!bricks/experimental/keep.yaml
+`))
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{path: "golang.yaml", expected: false},
+		{path: "next.draft.yaml", expected: true},
+		{path: "bricks/release/next.draft.yaml", expected: true},
+		{path: "drafts", isDir: true, expected: true},
+		{path: "bricks/drafts", isDir: true, expected: true},
+		{path: "drafts", isDir: false, expected: false},
+		{path: "local.yaml", expected: true},
+		{path: "bricks/local.yaml", expected: false},
+		{path: "bricks/experimental", isDir: true, expected: false},
+		{path: "bricks/experimental/new.yaml", expected: true},
+		{path: "bricks/experimental/keep.yaml", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			t.Parallel()
+			actual := rules.match(tt.path, tt.isDir)
+			if actual != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
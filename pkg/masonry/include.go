@@ -0,0 +1,310 @@
+package masonry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// includeField is the field of a document listing the blueprint files to include.
+const includeField = "include"
+
+var (
+	sha256DigestRegexp = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+	gitCommitRegexp    = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+// Include is a blueprint file included by another blueprint file. It is either:
+//   - a local file: only the path - relative to the including file - is set.
+//     It can also be written as a plain string.
+//   - a remote file: the HTTP(S) URL, pinned by its sha256 digest.
+//   - a file from a git repository: the repository URL, the path of the file in the repository,
+//     and the ref - a full commit SHA.
+type Include struct {
+	Path   string `json:"path,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Digest string `json:"digest,omitempty"`
+	Git    string `json:"git,omitempty"`
+	Ref    string `json:"ref,omitempty"`
+}
+
+func (i *Include) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*i = Include{Path: path}
+		return nil
+	}
+
+	type include Include // without the UnmarshalJSON method
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode((*include)(i))
+}
+
+func (i Include) String() string {
+	switch {
+	case i.URL != "":
+		return i.URL
+	case i.Git != "":
+		return fmt.Sprintf("git::%s//%s?ref=%s", i.Git, i.Path, i.Ref)
+	default:
+		return i.Path
+	}
+}
+
+func (i Include) Validate() error {
+	switch {
+	case i.URL != "":
+		if !strings.HasPrefix(i.URL, "https://") && !strings.HasPrefix(i.URL, "http://") {
+			return fmt.Errorf("unsupported URL %q: must be an HTTP(S) URL", i.URL)
+		}
+		if !sha256DigestRegexp.MatchString(i.Digest) {
+			return fmt.Errorf("invalid digest %q for URL %s: must be sha256:<hex>", i.Digest, i.URL)
+		}
+		if i.Path != "" || i.Git != "" || i.Ref != "" {
+			return fmt.Errorf("invalid include for URL %s: path, git and ref are not supported", i.URL)
+		}
+	case i.Git != "":
+		if !gitCommitRegexp.MatchString(i.Ref) {
+			return fmt.Errorf("invalid ref %q for git repository %s: must be a full commit SHA", i.Ref, i.Git)
+		}
+		if i.Path == "" {
+			return fmt.Errorf("missing path for git repository %s", i.Git)
+		}
+		if i.Digest != "" {
+			return fmt.Errorf("invalid include for git repository %s: digest is not supported", i.Git)
+		}
+	default:
+		if i.Path == "" {
+			return errors.New("missing path, url or git")
+		}
+		if i.Digest != "" || i.Ref != "" {
+			return fmt.Errorf("invalid include for path %s: digest and ref are only supported for remote files", i.Path)
+		}
+	}
+	return nil
+}
+
+// includes returns the files included by the document.
+// A document with includes can't define a brick.
+func (d document) includes() ([]Include, error) {
+	if d.isBrick() {
+		return nil, fmt.Errorf("a document with an %q field can't define a brick", includeField)
+	}
+	if len(d.Fields) > 1 {
+		return nil, fmt.Errorf("a document with an %q field can't have other fields", includeField)
+	}
+
+	data, err := json.Marshal(d.Fields[includeField])
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode includes: %w", err)
+	}
+	var includes []Include
+	err = json.Unmarshal(data, &includes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid includes: %w", err)
+	}
+	for _, include := range includes {
+		if err = include.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid include: %w", err)
+		}
+	}
+	return includes, nil
+}
+
+// blueprintFile is a blueprint file to load, either from the workspace or included by another file.
+type blueprintFile struct {
+	name string   // displayed in the bricks source
+	key  string   // unique identifier of the file, to load it once
+	path string   // local path of the file - in the cache for the remote files
+	dir  string   // local directory to resolve the relative includes from - only for local files
+	git  *Include // the git repository of the file - only for files from git
+}
+
+func (w Workspace) localBlueprintFile(filePath string) blueprintFile {
+	name := filePath
+	if relativePath, err := filepath.Rel(w.MasonDir(), filePath); err == nil {
+		name = filepath.ToSlash(relativePath)
+	}
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		absPath = filePath
+	}
+	return blueprintFile{
+		name: name,
+		key:  absPath,
+		path: filePath,
+		dir:  filepath.Dir(filePath),
+	}
+}
+
+// resolveInclude returns the file included by another file, downloading it if it is a remote file.
+func (w Workspace) resolveInclude(include Include, from blueprintFile) (*blueprintFile, error) {
+	switch {
+	case include.URL != "":
+		return w.downloadInclude(include)
+	case include.Git != "":
+		return w.checkoutInclude(include)
+	case from.git != nil:
+		// relative to the including file, in the same repository and ref
+		includePath := include.Path
+		if !path.IsAbs(includePath) {
+			includePath = path.Join(path.Dir(from.git.Path), includePath)
+		}
+		return w.checkoutInclude(Include{
+			Git:  from.git.Git,
+			Ref:  from.git.Ref,
+			Path: includePath,
+		})
+	case from.dir != "":
+		includePath := filepath.FromSlash(include.Path)
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(from.dir, includePath)
+		}
+		file := w.localBlueprintFile(includePath)
+		return &file, nil
+	default:
+		return nil, fmt.Errorf("relative include %s is not supported from the remote file %s", include.Path, from.name)
+	}
+}
+
+// downloadInclude downloads a remote file to the cache, and checks its digest.
+// Files already in the cache are not downloaded again.
+func (w Workspace) downloadInclude(include Include) (*blueprintFile, error) {
+	digest := strings.TrimPrefix(include.Digest, "sha256:")
+	cachedFilePath := filepath.Join(w.mason.CacheDir, "includes", "sha256", digest)
+	file := &blueprintFile{
+		name: include.String(),
+		key:  include.Digest,
+		path: cachedFilePath,
+	}
+	if content, err := os.ReadFile(cachedFilePath); err == nil && sha256Digest(content) == include.Digest {
+		w.logger().WithFields("url", include.URL).Debug("Using cached include")
+		return file, nil
+	}
+
+	w.logger().WithFields("url", include.URL).Info("Downloading include")
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Get(include.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", include.URL, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // we're just reading the body...
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", include.URL, resp.Status)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", include.URL, err)
+	}
+	if actualDigest := sha256Digest(content); actualDigest != include.Digest {
+		return nil, fmt.Errorf("digest mismatch for %s: expected %s, got %s", include.URL, include.Digest, actualDigest)
+	}
+
+	err = writeFileAtomically(cachedFilePath, content)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// checkoutInclude fetches the ref of a git repository to the cache, and returns the included file.
+// Refs already in the cache are not fetched again.
+func (w Workspace) checkoutInclude(include Include) (*blueprintFile, error) {
+	includePath := path.Clean(include.Path)
+	if path.IsAbs(includePath) || includePath == ".." || strings.HasPrefix(includePath, "../") {
+		return nil, fmt.Errorf("path %s is outside of the git repository %s", include.Path, include.Git)
+	}
+	include.Path = includePath
+
+	repositoryHash := sha256.Sum256([]byte(include.Git))
+	checkoutDir := filepath.Join(w.mason.CacheDir, "includes", "git", hex.EncodeToString(repositoryHash[:8]), include.Ref)
+	if _, err := os.Stat(checkoutDir); err != nil {
+		w.logger().WithFields("repository", include.Git, "ref", include.Ref).Info("Fetching include")
+		err = gitCheckout(include.Git, include.Ref, checkoutDir)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		w.logger().WithFields("repository", include.Git, "ref", include.Ref).Debug("Using cached include")
+	}
+
+	return &blueprintFile{
+		name: include.String(),
+		key:  include.String(),
+		path: filepath.Join(checkoutDir, filepath.FromSlash(includePath)),
+		git:  &include,
+	}, nil
+}
+
+// gitCheckout fetches a single commit of a git repository, and checks it out in the given directory.
+func gitCheckout(repository, commit, dir string) error {
+	err := os.MkdirAll(filepath.Dir(dir), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(dir), err)
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dir), ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir) //nolint:errcheck // best effort, it's empty once renamed
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"fetch", "--quiet", "--depth=1", repository, commit},
+		{"-c", "advice.detachedHead=false", "checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		if _, err = runGit(tmpDir, args...); err != nil {
+			return err
+		}
+	}
+
+	err = os.Rename(tmpDir, dir)
+	if err != nil {
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return nil // checked out concurrently
+		}
+		return fmt.Errorf("failed to move %s to %s: %w", tmpDir, dir, err)
+	}
+	return nil
+}
+
+func sha256Digest(content []byte) string {
+	hash := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+func writeFileAtomically(filePath string, content []byte) error {
+	err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(filePath), err)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), ".tmp-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmpFile.Name()) //nolint:errcheck // best effort, it doesn't exist once renamed
+
+	_, err = tmpFile.Write(content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %w", tmpFile.Name(), err)
+	}
+	err = os.Rename(tmpFile.Name(), filePath)
+	if err != nil {
+		return fmt.Errorf("failed to move %s to %s: %w", tmpFile.Name(), filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package masonry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gookit/color"
+	"github.com/pborman/indent"
+	"github.com/vbehar/mason/pkg/dagger"
+	"github.com/wagoodman/go-partybus"
+)
+
+// IsolatedScript is the merged script of a brick with a timeout or retries.
+// It runs in its own Dagger invocation, after the main script,
+// with the definitions of the variables it uses - which are run again, at each attempt.
+type IsolatedScript struct {
+	Brick  Brick
+	Script string
+}
+
+// isolateScripts splits the scripts of the bricks with a timeout or retries from the other scripts.
+// The isolated scripts are ordered by the dependencies of their bricks.
+func (p Plan) isolateScripts(scripts []Script) ([]Script, []IsolatedScript, error) {
+	isolatedBricks := make(map[string]Brick)
+	for _, brick := range p.blueprint.Bricks {
+		if brick.Metadata.IsIsolated() {
+			isolatedBricks[brick.ID()] = brick
+		}
+	}
+
+	var (
+		mainScripts    []Script
+		scriptsByBrick = make(map[string][]Script)
+	)
+	for _, script := range scripts {
+		if _, ok := isolatedBricks[script.Brick]; ok {
+			scriptsByBrick[script.Brick] = append(scriptsByBrick[script.Brick], script)
+			continue
+		}
+		mainScripts = append(mainScripts, script)
+	}
+	if len(scriptsByBrick) == 0 {
+		return scripts, nil, nil
+	}
+
+	scriptsDAG, err := newScriptsDAG(scripts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var orderedBricks []Brick
+	for len(orderedBricks) < len(scriptsByBrick) {
+		progress := false
+		for _, brick := range p.blueprint.Bricks {
+			brickScripts, ok := scriptsByBrick[brick.ID()]
+			if !ok || containsBrick(orderedBricks, brick) {
+				continue
+			}
+			ready := true
+			for _, dependency := range brickScripts[0].DependsOn {
+				if _, ok := scriptsByBrick[dependency]; ok && !containsBrick(orderedBricks, isolatedBricks[dependency]) {
+					ready = false
+				}
+			}
+			if ready {
+				orderedBricks = append(orderedBricks, brick)
+				progress = true
+			}
+		}
+		if !progress {
+			return nil, nil, errors.New("dependency cycle between the bricks with a timeout or retries")
+		}
+	}
+
+	var isolatedScripts []IsolatedScript
+	for _, brick := range orderedBricks {
+		brickScripts := scriptsByBrick[brick.ID()]
+		// the scripts of the other bricks run before: they can't depend on the isolated scripts
+		for _, script := range brickScripts {
+			children, err := scriptsDAG.GetChildren(string(script.Content))
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to get the scripts depending on %q: %w", script.Name, err)
+			}
+			for _, child := range children {
+				childScript, ok := child.(*Script)
+				if !ok {
+					continue
+				}
+				if _, ok := isolatedBricks[childScript.Brick]; !ok {
+					return nil, nil, fmt.Errorf("brick %s %s runs in its own Dagger invocation - because of its timeout or retries - "+
+						"so the script %q can't depend on its script %q", brick.Kind, brick.Metadata.Name, childScript.Name, script.Name)
+				}
+			}
+		}
+
+		ancestors, err := scriptsDAG.variableAncestors(brickScripts)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, ancestor := range ancestors {
+			// the other statements of the ancestors - such as the exports - already ran in the main script
+			ancestors[i].Content = ancestor.Content.Definitions()
+			if ancestors[i].Content.HasSideEffects() {
+				return nil, nil, fmt.Errorf("brick %s %s runs in its own Dagger invocation - because of its timeout or retries - "+
+					"so the definitions of the script %q are run again: they can't have side effects, such as export or publish",
+					brick.Kind, brick.Metadata.Name, ancestor.Name)
+			}
+		}
+		mergedScript, err := mergeScripts(append(ancestors, brickScripts...))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to merge the scripts of brick %s %s: %w", brick.Kind, brick.Metadata.Name, err)
+		}
+		script := "#!/usr/bin/env dagger\n\n"
+		if p.Phase != "" {
+			script += fmt.Sprintf("# Phase: %s\n", p.Phase)
+		}
+		script += fmt.Sprintf("# Brick: %s %s\n\n", brick.Kind, brick.Metadata.Name)
+		isolatedScripts = append(isolatedScripts, IsolatedScript{
+			Brick:  brick,
+			Script: script + mergedScript,
+		})
+	}
+	return mainScripts, isolatedScripts, nil
+}
+
+func containsBrick(bricks []Brick, brick Brick) bool {
+	for _, b := range bricks {
+		if b.ID() == brick.ID() {
+			return true
+		}
+	}
+	return false
+}
+
+// variableAncestors returns the scripts defining the variables used by the given scripts - recursively.
+// The scripts ordered only by the dependencies of their bricks are not included.
+func (d scriptsDAG) variableAncestors(scripts []Script) ([]Script, error) {
+	var (
+		ancestors []Script
+		visited   = make(map[string]bool)
+		queue     []string
+	)
+	for _, script := range scripts {
+		visited[string(script.Content)] = true
+		queue = append(queue, string(script.Content))
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		parents, err := d.GetParents(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the parents of a script: %w", err)
+		}
+		for parentID, parent := range parents {
+			if visited[parentID] || len(d.edgesVariables[[2]string{parentID, id}]) == 0 {
+				continue
+			}
+			parentScript, ok := parent.(*Script)
+			if !ok {
+				return nil, fmt.Errorf("failed to cast vertex %q to Script", parentID)
+			}
+			visited[parentID] = true
+			ancestors = append(ancestors, *parentScript)
+			queue = append(queue, parentID)
+		}
+	}
+	return ancestors, nil
+}
+
+// runIsolatedScript runs the script of a brick with a timeout or retries, until it succeeds or has no attempts left.
+func (p Plan) runIsolatedScript(isolated IsolatedScript) error {
+	brick := isolated.Brick
+	planFileName := fmt.Sprintf("plan_%s_%s.dagger", p.Phase, brick.ID())
+	planFilePath := filepath.Join(p.DirPath, planFileName)
+	p.logger().WithFields("path", planFilePath).
+		Tracef("Writing Dagger script to disk:\n%+v\n",
+			color.Note.Sprint(indent.String("  ", isolated.Script)),
+		)
+	err := os.WriteFile(planFilePath, []byte(isolated.Script), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write plan file %q: %w", planFilePath, err)
+	}
+
+	secrets, err := p.blueprint.workspace.resolveSecrets()
+	if err != nil {
+		return err
+	}
+
+	logFileName := fmt.Sprintf("dagger_%s_%s.log", p.Phase, brick.ID())
+	logFilePath := filepath.Join(p.DirPath, logFileName)
+	logFile, err := os.Create(logFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to create log file %q: %w", logFilePath, err)
+	}
+	defer func() {
+		err := logFile.Close()
+		if err != nil {
+			p.logger().WithFields("path", logFilePath).
+				Errorf("Failed to close log file: %s", err)
+		}
+	}()
+
+	attempts := brick.Metadata.Retries + 1
+	var runErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		source := map[string]string{
+			"workspace": p.blueprint.workspace.RelativePath,
+			"phase":     p.Phase,
+			"brick":     brick.Metadata.Name,
+			"attempt":   strconv.Itoa(attempt),
+			"attempts":  strconv.Itoa(attempts),
+		}
+		p.blueprint.workspace.mason.EventBus.Publish(partybus.Event{
+			Type:   EventTypeApplyBrick,
+			Source: source,
+		})
+
+		p.logger().WithFields("script", planFilePath, "brick", brick.Metadata.Name, "attempt", attempt, "attempts", attempts).
+			Info("Applying brick with Dagger")
+		var daggerOutWriter bytes.Buffer
+		runErr = dagger.ExecScript(dagger.ExecScriptOpts{
+			BinaryPath:    p.blueprint.workspace.mason.DaggerBinary,
+			Logger:        p.logger(),
+			ScriptPath:    planFilePath,
+			Dir:           p.blueprint.workspace.Dir(),
+			Env:           secrets.daggerEnv,
+			Args:          p.blueprint.workspace.mason.DaggerArgs,
+			DisableOutput: p.blueprint.workspace.mason.DaggerOutputDisabled,
+			Secrets:       secrets.values,
+			Timeout:       time.Duration(brick.Metadata.Timeout),
+			Stdout:        &daggerOutWriter,
+			Stderr:        logFile,
+		})
+
+		// parse/write the dagger output before handling the error
+		// to make sure we don't lose it before returning
+		output := strings.TrimSpace(daggerOutWriter.String())
+		p.logger().Infof("Dagger output:\n%+v\n",
+			color.Success.Sprint(indent.String("  ", output)),
+		)
+		if output != "" {
+			p.blueprint.workspace.mason.EventBus.Publish(partybus.Event{
+				Type:   EventTypeDaggerOutput,
+				Source: maps.Clone(source),
+				Value:  output,
+			})
+		}
+
+		if errors.Is(runErr, context.DeadlineExceeded) {
+			timeoutSource := maps.Clone(source)
+			timeoutSource["timeout"] = brick.Metadata.Timeout.String()
+			p.blueprint.workspace.mason.EventBus.Publish(partybus.Event{
+				Type:   EventTypeBrickTimeout,
+				Source: timeoutSource,
+			})
+		}
+		if runErr == nil {
+			return nil
+		}
+		if attempt < attempts {
+			p.logger().WithFields("brick", brick.Metadata.Name, "attempt", attempt, "attempts", attempts).
+				Warnf("Failed to apply brick, retrying: %s", runErr)
+		}
+	}
+	return fmt.Errorf("failed to run brick %s %s after %d attempt(s): %w", brick.Kind, brick.Metadata.Name, attempts, runErr)
+}
@@ -0,0 +1,194 @@
+package masonry
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanIsolatedScripts(t *testing.T) {
+	t.Parallel()
+
+	bricks := []Brick{
+		{Kind: "GoBinary", Metadata: BrickMetadata{Name: "mason"}},
+		{Kind: "RunBinary", Metadata: BrickMetadata{Name: "version", Retries: 2}},
+		{Kind: "Publish", Metadata: BrickMetadata{Name: "release", Timeout: Duration(time.Minute), DependsOn: []string{"version"}}},
+	}
+
+	tests := []struct {
+		name                   string
+		sourceScripts          []Script
+		expectedScript         string
+		expectedIsolatedBricks []string
+		expectedIsolated       []string
+		expectedError          string
+	}{
+		{
+			name: "isolated scripts with the scripts defining their variables",
+			sourceScripts: []Script{
+				{
+					Name:    "release",
+					Content: "$mason_bin | export release/mason",
+					Brick:   "publish_release",
+					// dependencies on isolated bricks only order the isolated scripts
+					DependsOn: []string{"runbinary_version"},
+				},
+				{
+					Name:    "version",
+					Content: "container | with-file /bin/mason $mason_bin | with-exec mason,version | stdout",
+					Brick:   "runbinary_version",
+				},
+				{
+					Name:    "mason",
+					Content: "mason_bin=$(container | from golang | file /go/bin/mason)",
+					Brick:   "gobinary_mason",
+				},
+			},
+			expectedScript: `#!/usr/bin/env dagger
+
+# Phase: package
+
+# mason
+mason_bin=$(container | from golang | file /go/bin/mason)
+.echo`,
+			expectedIsolatedBricks: []string{"runbinary_version", "publish_release"},
+			expectedIsolated: []string{`#!/usr/bin/env dagger
+
+# Phase: package
+# Brick: RunBinary version
+
+# mason
+mason_bin=$(container | from golang | file /go/bin/mason)
+.echo
+
+# version
+container | with-file /bin/mason $mason_bin | with-exec mason,version | stdout
+.echo`, `#!/usr/bin/env dagger
+
+# Phase: package
+# Brick: Publish release
+
+# mason
+mason_bin=$(container | from golang | file /go/bin/mason)
+.echo
+
+# release
+$mason_bin | export release/mason
+.echo`},
+		},
+		{
+			name: "only the definitions of the ancestors are run again",
+			sourceScripts: []Script{
+				{
+					Name:    "version",
+					Content: "container | with-file /bin/mason $mason_bin | with-exec mason,version | stdout",
+					Brick:   "runbinary_version",
+				},
+				{
+					Name: "mason",
+					Content: `# build
+mason_bin=$(container |
+  from golang |
+  file /go/bin/mason)
+$mason_bin | export bin/mason`,
+					Brick: "gobinary_mason",
+				},
+			},
+			expectedScript: `#!/usr/bin/env dagger
+
+# Phase: package
+
+# mason
+# build
+mason_bin=$(container |
+  from golang |
+  file /go/bin/mason)
+$mason_bin | export bin/mason
+.echo`,
+			expectedIsolatedBricks: []string{"runbinary_version"},
+			expectedIsolated: []string{`#!/usr/bin/env dagger
+
+# Phase: package
+# Brick: RunBinary version
+
+# mason
+mason_bin=$(container |
+  from golang |
+  file /go/bin/mason)
+.echo
+
+# version
+container | with-file /bin/mason $mason_bin | with-exec mason,version | stdout
+.echo`},
+		},
+		{
+			name: "ancestor definition with side effects",
+			sourceScripts: []Script{
+				{
+					Name:    "version",
+					Content: "container | from $image | with-exec mason,version | stdout",
+					Brick:   "runbinary_version",
+				},
+				{
+					Name:    "mason",
+					Content: "image=$(container | from golang | publish ttl.sh/mason)",
+					Brick:   "gobinary_mason",
+				},
+			},
+			expectedError: `brick RunBinary version runs in its own Dagger invocation - because of its timeout or retries - so the definitions of the script "mason" are run again: they can't have side effects, such as export or publish`,
+		},
+		{
+			name: "script depending on an isolated script",
+			sourceScripts: []Script{
+				{
+					Name:    "version",
+					Content: "version=$(container | from alpine | with-exec mason,version | stdout)",
+					Brick:   "runbinary_version",
+				},
+				{
+					Name:    "mason",
+					Content: "container | from golang | with-env-variable VERSION $version",
+					Brick:   "gobinary_mason",
+				},
+			},
+			expectedError: `brick RunBinary version runs in its own Dagger invocation - because of its timeout or retries - so the script "mason" can't depend on its script "version"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			plan := &Plan{
+				Phase:         "package",
+				SourceScripts: tt.sourceScripts,
+				blueprint:     Blueprint{Bricks: bricks},
+			}
+			err := plan.computeFinalScripts()
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if plan.MergedScript != tt.expectedScript {
+				t.Errorf("expected merged script:\n%s\n\ngot:\n%s", tt.expectedScript, plan.MergedScript)
+			}
+			if len(plan.IsolatedScripts) != len(tt.expectedIsolated) {
+				t.Fatalf("expected %d isolated scripts, got %d", len(tt.expectedIsolated), len(plan.IsolatedScripts))
+			}
+			for i, isolated := range plan.IsolatedScripts {
+				if isolated.Brick.ID() != tt.expectedIsolatedBricks[i] {
+					t.Errorf("expected isolated script %d for brick %s, got %s", i, tt.expectedIsolatedBricks[i], isolated.Brick.ID())
+				}
+				if isolated.Script != tt.expectedIsolated[i] {
+					t.Errorf("expected isolated script %d:\n%s\n\ngot:\n%s", i, tt.expectedIsolated[i], isolated.Script)
+				}
+			}
+		})
+	}
+}
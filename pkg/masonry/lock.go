@@ -0,0 +1,138 @@
+package masonry
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/rs/xid"
+)
+
+const lockFileHeader = "# Generated by mason lock - DO NOT EDIT.\n"
+
+// ModuleLock pins the module references used by the blueprint of a workspace to immutable commits.
+type ModuleLock struct {
+	Modules map[ModuleRef]LockedModule `json:"modules"`
+}
+
+type LockedModule struct {
+	Commit string    `json:"commit"`
+	Ref    ModuleRef `json:"ref"` // the module reference, pinned to the commit
+}
+
+// PinnedTo returns the module reference with its version replaced by the given commit.
+func (m ModuleRef) PinnedTo(commit string) ModuleRef {
+	return ModuleRef(string(m.Base()) + "@" + commit)
+}
+
+// IsLocal returns true if the module reference is a local path, which can't be pinned.
+func (m ModuleRef) IsLocal() bool {
+	return strings.HasPrefix(string(m), ".") || filepath.IsAbs(string(m))
+}
+
+func (w Workspace) LockFilePath() string {
+	return filepath.Join(w.MasonDir(), LockFileName)
+}
+
+// LoadLock reads the lock file of the workspace - if it exists.
+func (w Workspace) LoadLock() (*ModuleLock, error) {
+	lock := ModuleLock{Modules: make(map[ModuleRef]LockedModule)}
+	content, err := os.ReadFile(w.LockFilePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return &lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file %s: %w", w.LockFilePath(), err)
+	}
+
+	err = yaml.Unmarshal(content, &lock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode lock file %s: %w", w.LockFilePath(), err)
+	}
+	if lock.Modules == nil {
+		lock.Modules = make(map[ModuleRef]LockedModule)
+	}
+	return &lock, nil
+}
+
+func (w Workspace) WriteLock(lock ModuleLock) error {
+	content, err := yaml.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("failed to encode lock file: %w", err)
+	}
+	err = os.WriteFile(w.LockFilePath(), append([]byte(lockFileHeader), content...), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write lock file %s: %w", w.LockFilePath(), err)
+	}
+	return nil
+}
+
+// pinnedModuleRef returns the module reference to use in the Dagger scripts:
+// the one pinned in the lock file, if any.
+// In locked mode, a module missing from the lock file is an error.
+func (w Workspace) pinnedModuleRef(moduleRef ModuleRef) (ModuleRef, error) {
+	if lockedModule, ok := w.Lock.Modules[moduleRef]; ok {
+		return lockedModule.Ref, nil
+	}
+	if w.mason.Locked && !moduleRef.IsLocal() {
+		return "", fmt.Errorf("module %s is not in the lock file %s - run mason lock to update it", moduleRef, w.LockFilePath())
+	}
+	return moduleRef, nil
+}
+
+// Lock resolves the module references used by the blueprint to their commits, with Dagger.
+// Local modules are not locked.
+func (b Blueprint) Lock() (*ModuleLock, error) {
+	lock := ModuleLock{Modules: make(map[ModuleRef]LockedModule)}
+	moduleRefs := slices.Sorted(maps.Keys(b.splitByModuleRef()))
+	lockName := xid.New().String()
+	for _, moduleRef := range moduleRefs {
+		if moduleRef.IsLocal() {
+			b.logger().WithFields("module", moduleRef).Debug("Not locking local module")
+			continue
+		}
+
+		commit, err := b.workspace.resolveModuleCommit(moduleRef, lockName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve module %s: %w", moduleRef, err)
+		}
+		lock.Modules[moduleRef] = LockedModule{
+			Commit: commit,
+			Ref:    moduleRef.PinnedTo(commit),
+		}
+	}
+	return &lock, nil
+}
+
+// resolveModuleCommit returns the commit of a module reference, as resolved by Dagger.
+func (w Workspace) resolveModuleCommit(moduleRef ModuleRef, lockName string) (string, error) {
+	moduleLockDir := filepath.Join(w.WorkDir(), lockName, LockDirPrefix, moduleRef.SanitizedName())
+	err := os.MkdirAll(moduleLockDir, os.ModePerm)
+	if err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", moduleLockDir, err)
+	}
+
+	daggerScript := "#!/usr/bin/env dagger\n\n"
+	daggerScript += fmt.Sprintf("module-source %s | commit\n", moduleRef)
+	daggerScriptFilePath := filepath.Join(moduleLockDir, "lock.dagger")
+	err = os.WriteFile(daggerScriptFilePath, []byte(daggerScript), 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write file %q: %w", daggerScriptFilePath, err)
+	}
+
+	w.logger().WithFields("module", moduleRef).Info("Resolving module commit with Dagger")
+	commit, err := w.execDaggerScript(daggerScriptFilePath, filepath.Join(moduleLockDir, "dagger_lock.log"))
+	if err != nil {
+		return "", err
+	}
+	if !gitCommitRegexp.MatchString(commit) {
+		return "", fmt.Errorf("invalid commit %q", commit)
+	}
+	return commit, nil
+}
@@ -0,0 +1,260 @@
+package masonry
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestModuleRefPinnedTo(t *testing.T) {
+	t.Parallel()
+
+	const commit = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	tests := []struct {
+		moduleRef ModuleRef
+		expected  ModuleRef
+	}{
+		{
+			moduleRef: "github.com/vbehar/mason-modules/golang@v0.0.5",
+			expected:  "github.com/vbehar/mason-modules/golang@" + commit,
+		},
+		{
+			moduleRef: "github.com/vbehar/mason-modules/golang",
+			expected:  "github.com/vbehar/mason-modules/golang@" + commit,
+		},
+		{
+			moduleRef: "git@github.com:vbehar/mason-modules.git/golang@main",
+			expected:  "git@github.com:vbehar/mason-modules.git/golang@" + commit,
+		},
+		{
+			moduleRef: "git@github.com:vbehar/mason-modules.git/golang",
+			expected:  "git@github.com:vbehar/mason-modules.git/golang@" + commit,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.moduleRef), func(t *testing.T) {
+			t.Parallel()
+			actual := tt.moduleRef.PinnedTo(commit)
+			if actual != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestBlueprintLock(t *testing.T) {
+	t.Parallel()
+
+	// fake Dagger CLI, resolving the module-source scripts
+	daggerBinary := filepath.Join(t.TempDir(), "dagger")
+	writeFile(t, daggerBinary, `#!/bin/sh
+for script; do :; done
+case "$(cat "$script")" in
+  *"module-source github.com/vbehar/mason-modules/golang@v0.0.5 | commit"*) echo 4b825dc642cb6eb9a060e54bf8d69288fbee4904 ;;
+  *"module-source github.com/vbehar/mason-modules/run@v0.0.5 | commit"*) echo d670460b4b4aece5915caf5c68d12f560a9fe3e4 ;;
+  *) echo "unknown module" >&2; exit 1 ;;
+esac
+`)
+	if err := os.Chmod(daggerBinary, 0755); err != nil {
+		t.Fatalf("failed to make the fake Dagger CLI executable: %v", err)
+	}
+
+	rootPath := t.TempDir()
+	writeFile(t, filepath.Join(rootPath, MasonDirName, "bricks.yaml"), `kind: GoBinary
+moduleRef: github.com/vbehar/mason-modules/golang@v0.0.5
+metadata:
+  name: linux
+---
+kind: GoTest
+moduleRef: github.com/vbehar/mason-modules/golang@v0.0.5
+metadata:
+  name: unit
+---
+kind: RunBinary
+moduleRef: github.com/vbehar/mason-modules/run@v0.0.5
+metadata:
+  name: server
+---
+kind: Custom
+moduleRef: ./modules/custom
+metadata:
+  name: custom
+`)
+
+	mason := NewMason()
+	mason.DaggerBinary = daggerBinary
+	workspace := Workspace{
+		RootPath:     rootPath,
+		RelativePath: ".",
+		mason:        mason,
+		workDirName:  "test",
+	}
+	blueprint, err := workspace.LoadBlueprint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lock, err := blueprint.Lock()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedLock := &ModuleLock{
+		Modules: map[ModuleRef]LockedModule{
+			"github.com/vbehar/mason-modules/golang@v0.0.5": {
+				Commit: "4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+				Ref:    "github.com/vbehar/mason-modules/golang@4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+			},
+			"github.com/vbehar/mason-modules/run@v0.0.5": {
+				Commit: "d670460b4b4aece5915caf5c68d12f560a9fe3e4",
+				Ref:    "github.com/vbehar/mason-modules/run@d670460b4b4aece5915caf5c68d12f560a9fe3e4",
+			},
+		},
+	}
+	if !reflect.DeepEqual(lock, expectedLock) {
+		t.Fatalf("expected lock %+v, got %+v", expectedLock, lock)
+	}
+
+	if err = workspace.WriteLock(*lock); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loadedLock, err := workspace.LoadLock()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loadedLock, expectedLock) {
+		t.Fatalf("expected loaded lock %+v, got %+v", expectedLock, loadedLock)
+	}
+	workspace.Lock = *loadedLock
+
+	tests := []struct {
+		name          string
+		locked        bool
+		moduleRef     ModuleRef
+		expected      ModuleRef
+		expectedError string
+	}{
+		{
+			name:      "pinned module",
+			moduleRef: "github.com/vbehar/mason-modules/golang@v0.0.5",
+			expected:  "github.com/vbehar/mason-modules/golang@4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+		},
+		{
+			name:      "pinned module in locked mode",
+			locked:    true,
+			moduleRef: "github.com/vbehar/mason-modules/run@v0.0.5",
+			expected:  "github.com/vbehar/mason-modules/run@d670460b4b4aece5915caf5c68d12f560a9fe3e4",
+		},
+		{
+			name:      "missing module",
+			moduleRef: "github.com/vbehar/mason-modules/golang@v0.0.6",
+			expected:  "github.com/vbehar/mason-modules/golang@v0.0.6",
+		},
+		{
+			name:          "missing module in locked mode",
+			locked:        true,
+			moduleRef:     "github.com/vbehar/mason-modules/golang@v0.0.6",
+			expectedError: "module github.com/vbehar/mason-modules/golang@v0.0.6 is not in the lock file",
+		},
+		{
+			name:      "local module in locked mode",
+			locked:    true,
+			moduleRef: "./modules/custom",
+			expected:  "./modules/custom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			lockedMason := *mason
+			lockedMason.Locked = tt.locked
+			lockedWorkspace := workspace
+			lockedWorkspace.mason = &lockedMason
+
+			actual, err := lockedWorkspace.pinnedModuleRef(tt.moduleRef)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error to contain %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if actual != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestBlueprintRenderPlanWithLock(t *testing.T) {
+	t.Parallel()
+
+	// fake Dagger CLI, recording the script it runs - and rendering an empty plan
+	daggerDir := t.TempDir()
+	daggerBinary := filepath.Join(daggerDir, "dagger")
+	writeFile(t, daggerBinary, `#!/bin/sh
+for script; do :; done
+cp "$script" "$(dirname "$0")/recorded.dagger"
+`)
+	if err := os.Chmod(daggerBinary, 0755); err != nil {
+		t.Fatalf("failed to make the fake Dagger CLI executable: %v", err)
+	}
+
+	rootPath := t.TempDir()
+	writeFile(t, filepath.Join(rootPath, MasonDirName, "bricks.yaml"), `kind: GoBinary
+moduleRef: github.com/vbehar/mason-modules/golang@v0.0.5
+metadata:
+  name: linux
+---
+kind: RunBinary
+moduleRef: github.com/vbehar/mason-modules/run@v0.0.5
+metadata:
+  name: server
+`)
+
+	mason := NewMason()
+	mason.DaggerBinary = daggerBinary
+	mason.DaggerOutputDisabled = true
+	workspace := Workspace{
+		RootPath:     rootPath,
+		RelativePath: ".",
+		Lock: ModuleLock{Modules: map[ModuleRef]LockedModule{
+			"github.com/vbehar/mason-modules/golang@v0.0.5": {
+				Commit: "4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+				Ref:    "github.com/vbehar/mason-modules/golang@4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+			},
+		}},
+		mason:       mason,
+		workDirName: "test",
+	}
+	blueprint, err := workspace.LoadBlueprint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err = blueprint.RenderPlan(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	script, err := os.ReadFile(filepath.Join(daggerDir, "recorded.dagger"))
+	if err != nil {
+		t.Fatalf("failed to read the recorded script: %v", err)
+	}
+	for _, expected := range []string{
+		"$(github.com/vbehar/mason-modules/golang@4b825dc642cb6eb9a060e54bf8d69288fbee4904 | render-plan ",
+		// not pinned in the lock file
+		"$(github.com/vbehar/mason-modules/run@v0.0.5 | render-plan ",
+	} {
+		if !strings.Contains(string(script), expected) {
+			t.Errorf("expected the render-plan script to contain %q, got:\n%s", expected, script)
+		}
+	}
+	if strings.Contains(string(script), "golang@v0.0.5") {
+		t.Errorf("expected the render-plan script to use the pinned module, got:\n%s", script)
+	}
+}
@@ -1,14 +1,21 @@
 package masonry
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/anchore/go-logger"
 	"github.com/anchore/go-logger/adapter/discard"
+	"github.com/anchore/go-logger/adapter/redact"
+	"github.com/heimdalr/dag"
 	"github.com/rs/xid"
 	"github.com/wagoodman/go-partybus"
 )
@@ -20,22 +27,43 @@ type Mason struct {
 	DaggerArgs           []string
 	DaggerBinary         string
 	DaggerOutputDisabled bool
+	Parallelism          int
+	StrictBlueprint      bool
+	CacheDir             string            // for the remote blueprint includes
+	Variables            map[string]string // for the templated values of the bricks, overriding the workspaces variables
+	Profile              string            // name of the profile to apply to the blueprints, if any
+	Locked               bool              // fail if a module is not in the lock file of its workspace
+	Phases               []string          // requested phases, for the conditions of the bricks
 
-	EventBus *partybus.Bus
-	Logger   logger.Logger
+	EventBus    *partybus.Bus
+	Logger      logger.Logger
+	RedactStore redact.Store // if set, the values of the secrets are added to it
 
 	workspaces []Workspace
+	secrets    *sync.Map // resolved secrets, by workspace directory
 }
 
 func NewMason() *Mason {
 	return &Mason{
-		Logger:      discard.New(),
-		EventBus:    partybus.NewBus(),
-		RootPath:    ".",
-		IgnoredDirs: []string{".git"},
+		Logger:          discard.New(),
+		EventBus:        partybus.NewBus(),
+		RootPath:        ".",
+		IgnoredDirs:     []string{".git"},
+		Parallelism:     1,
+		StrictBlueprint: true,
+		CacheDir:        defaultCacheDir(),
+		secrets:         &sync.Map{},
 	}
 }
 
+func defaultCacheDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	return filepath.Join(cacheDir, "mason")
+}
+
 func (m *Mason) DetectWorkspaces() ([]Workspace, error) {
 	ignoredDirs := make(map[string]struct{})
 	for _, dir := range m.IgnoredDirs {
@@ -68,12 +96,24 @@ func (m *Mason) DetectWorkspaces() ([]Workspace, error) {
 			}
 
 			m.Logger.WithFields("dir", relativePath).Debug("Found workspace")
-			workspaces = append(workspaces, Workspace{
+			workspace := Workspace{
 				RootPath:     rootPath,
 				RelativePath: relativePath,
 				mason:        m,
 				workDirName:  xid.New().String(),
-			})
+			}
+			config, err := workspace.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load config of workspace %q: %w", relativePath, err)
+			}
+			workspace.Config = *config
+			lock, err := workspace.LoadLock()
+			if err != nil {
+				return fmt.Errorf("failed to load lock file of workspace %q: %w", relativePath, err)
+			}
+			workspace.Lock = *lock
+			workspaces = append(workspaces, workspace)
+			return fs.SkipDir // no need to walk the .mason directory itself
 		}
 
 		return nil
@@ -87,6 +127,163 @@ func (m *Mason) DetectWorkspaces() ([]Workspace, error) {
 	return workspaces, nil
 }
 
+// ForEachWorkspace runs fn for each workspace, with at most Parallelism workspaces running at the same time.
+// A workspace is only run once all its dependencies have been run successfully.
+func (m *Mason) ForEachWorkspace(workspaces []Workspace, fn func(Workspace) error) error {
+	orderedWorkspaces, err := orderWorkspaces(workspaces)
+	if err != nil {
+		return fmt.Errorf("failed to order workspaces: %w", err)
+	}
+
+	parallelism := max(m.Parallelism, 1)
+	m.Logger.WithFields("workspaces", len(workspaces), "parallelism", parallelism).
+		Debug("Running workspaces")
+
+	type result struct {
+		path string
+		err  error
+	}
+	var (
+		errs      error
+		failed    int
+		running   int
+		pending   = orderedWorkspaces
+		completed = make(chan result)
+		results   = make(map[string]error, len(orderedWorkspaces))
+	)
+	record := func(path string, err error) {
+		results[path] = err
+		if err != nil {
+			failed++
+			errs = errors.Join(errs, fmt.Errorf("workspace %q: %w", path, err))
+		}
+	}
+	for len(pending) > 0 || running > 0 {
+		// start the first workspaces - in order - whose dependencies are done,
+		// so that a slow workspace only delays its dependents
+		for i := 0; i < len(pending) && running < parallelism; {
+			workspace := pending[i]
+			ready := true
+			var depErr error
+			for _, dependency := range workspace.Config.DependsOn {
+				err, done := results[dependency]
+				if !done {
+					ready = false
+					break
+				}
+				if err != nil {
+					depErr = errors.Join(depErr, fmt.Errorf("dependency %q failed", dependency))
+				}
+			}
+			if !ready {
+				i++
+				continue
+			}
+
+			pending = slices.Delete(pending, i, i+1)
+			if depErr != nil {
+				m.Logger.WithFields("workspace", workspace.RelativePath).
+					Warnf("Skipping workspace: %s", depErr)
+				record(workspace.RelativePath, depErr)
+				i = 0 // its dependents may now be skipped too
+				continue
+			}
+			running++
+			go func() {
+				completed <- result{path: workspace.RelativePath, err: fn(workspace)}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+
+		finished := <-completed
+		running--
+		record(finished.path, finished.err)
+	}
+
+	if errs != nil {
+		return fmt.Errorf("failed to run %d/%d workspaces: %w", failed, len(workspaces), errs)
+	}
+	return nil
+}
+
+// orderWorkspaces returns the workspaces sorted by "depth" in the dependency graph - and then by path:
+// first the workspaces without dependencies, then the ones depending only on them, and so on.
+func orderWorkspaces(workspaces []Workspace) ([]Workspace, error) {
+	workspacesDAG := dag.NewDAG()
+	for i := range workspaces {
+		err := workspacesDAG.AddVertexByID(workspaces[i].RelativePath, &workspaces[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to add workspace %q to DAG: %w", workspaces[i].RelativePath, err)
+		}
+	}
+
+	// sorted, for the cycles to be reported the same way
+	sortedWorkspaces := slices.SortedFunc(slices.Values(workspaces), func(a, b Workspace) int {
+		return cmp.Compare(a.RelativePath, b.RelativePath)
+	})
+	for _, workspace := range sortedWorkspaces {
+		for _, dependency := range workspace.Config.DependsOn {
+			if _, err := workspacesDAG.GetVertex(dependency); err != nil {
+				return nil, fmt.Errorf("workspace %q depends on unknown workspace %q", workspace.RelativePath, dependency)
+			}
+			err := workspacesDAG.AddEdge(dependency, workspace.RelativePath)
+			if err != nil {
+				if errors.As(err, &dag.EdgeDuplicateError{}) {
+					continue
+				}
+				if errors.As(err, &dag.SrcDstEqualError{}) {
+					return nil, fmt.Errorf("dependency cycle: workspace %q depends on itself", workspace.RelativePath)
+				}
+				if errors.As(err, &dag.EdgeLoopError{}) {
+					cycle := append([]string{workspace.RelativePath}, dependencyChain(workspacesDAG, dependency, workspace.RelativePath)...)
+					return nil, fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+				}
+				return nil, fmt.Errorf("failed to add dependency from %q to %q: %w", workspace.RelativePath, dependency, err)
+			}
+		}
+	}
+
+	depths := make(map[string]int, len(workspaces))
+	var depthOf func(id string) int
+	depthOf = func(id string) int {
+		if depth, ok := depths[id]; ok {
+			return depth
+		}
+		depth := 0
+		parents, _ := workspacesDAG.GetParents(id)
+		for parentID := range parents {
+			depth = max(depth, depthOf(parentID)+1)
+		}
+		depths[id] = depth
+		return depth
+	}
+
+	orderedWorkspaces := slices.Clone(workspaces)
+	slices.SortStableFunc(orderedWorkspaces, func(a, b Workspace) int {
+		return cmp.Or(
+			cmp.Compare(depthOf(a.RelativePath), depthOf(b.RelativePath)),
+			cmp.Compare(a.RelativePath, b.RelativePath),
+		)
+	})
+	return orderedWorkspaces, nil
+}
+
+// dependencyChain returns the workspaces from one workspace to another - both included - each depending on the next one.
+func dependencyChain(workspacesDAG *dag.DAG, from, to string) []string {
+	if from == to {
+		return []string{to}
+	}
+	parents, _ := workspacesDAG.GetParents(from)
+	for _, parentID := range slices.Sorted(maps.Keys(parents)) {
+		if chain := dependencyChain(workspacesDAG, parentID, to); chain != nil {
+			return append([]string{from}, chain...)
+		}
+	}
+	return nil
+}
+
 func (m Mason) WorkDirs() []string {
 	workDirs := make([]string, 0, len(m.workspaces))
 	for _, workspace := range m.workspaces {
@@ -0,0 +1,327 @@
+package masonry
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMasonForEachWorkspace(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		parallelism    int
+		workspaces     []string
+		failing        map[string]bool
+		expectedErrors []string
+	}{
+		{
+			name:        "sequential",
+			parallelism: 1,
+			workspaces:  []string{"a", "b", "c"},
+		},
+		{
+			name:        "parallel",
+			parallelism: 2,
+			workspaces:  []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name:        "more slots than workspaces",
+			parallelism: 10,
+			workspaces:  []string{"a", "b"},
+		},
+		{
+			name:        "invalid parallelism",
+			parallelism: 0,
+			workspaces:  []string{"a", "b"},
+		},
+		{
+			name:        "failing workspaces",
+			parallelism: 2,
+			workspaces:  []string{"a", "b", "c"},
+			failing:     map[string]bool{"a": true, "c": true},
+			expectedErrors: []string{
+				"failed to run 2/3 workspaces",
+				`workspace "a": a failed`,
+				`workspace "c": c failed`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mason := NewMason()
+			mason.Parallelism = tt.parallelism
+
+			var workspaces []Workspace
+			for _, path := range tt.workspaces {
+				workspaces = append(workspaces, Workspace{RelativePath: path, mason: mason})
+			}
+
+			var running, maxRunning, calls atomic.Int32
+			err := mason.ForEachWorkspace(workspaces, func(w Workspace) error {
+				calls.Add(1)
+				current := running.Add(1)
+				defer running.Add(-1)
+				for {
+					previous := maxRunning.Load()
+					if current <= previous || maxRunning.CompareAndSwap(previous, current) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				if tt.failing[w.RelativePath] {
+					return fmt.Errorf("%s failed", w.RelativePath)
+				}
+				return nil
+			})
+
+			if int(calls.Load()) != len(tt.workspaces) {
+				t.Errorf("expected %d calls, got %d", len(tt.workspaces), calls.Load())
+			}
+			if limit := max(tt.parallelism, 1); int(maxRunning.Load()) > limit {
+				t.Errorf("expected at most %d workspaces running at the same time, got %d", limit, maxRunning.Load())
+			}
+
+			if len(tt.expectedErrors) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected errors %v, got nil", tt.expectedErrors)
+			}
+			for _, expectedError := range tt.expectedErrors {
+				if !strings.Contains(err.Error(), expectedError) {
+					t.Errorf("expected error to contain %q, got %v", expectedError, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMasonForEachWorkspaceWithDependencies(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		parallelism   int
+		dependencies  map[string][]string
+		failing       map[string]bool
+		expectedOrder []string
+		expectedError string
+	}{
+		{
+			name:        "no dependencies",
+			parallelism: 1,
+			dependencies: map[string][]string{
+				"services/api": nil,
+				"libs/proto":   nil,
+				".":            nil,
+			},
+			expectedOrder: []string{".", "libs/proto", "services/api"},
+		},
+		{
+			name:        "dependencies first",
+			parallelism: 1,
+			dependencies: map[string][]string{
+				"services/api":    {"libs/proto"},
+				"services/worker": {"libs/proto", "services/api"},
+				"libs/proto":      nil,
+				"libs/utils":      nil,
+			},
+			expectedOrder: []string{"libs/proto", "libs/utils", "services/api", "services/worker"},
+		},
+		{
+			name:        "dependencies first in parallel",
+			parallelism: 4,
+			dependencies: map[string][]string{
+				"services/api":    {"libs/proto"},
+				"services/worker": {"services/api"},
+				"libs/proto":      nil,
+			},
+			expectedOrder: []string{"libs/proto", "services/api", "services/worker"},
+		},
+		{
+			name:        "failed dependency",
+			parallelism: 2,
+			dependencies: map[string][]string{
+				"services/api": {"libs/proto"},
+				"libs/proto":   nil,
+				"libs/utils":   nil,
+			},
+			failing:       map[string]bool{"libs/proto": true},
+			expectedOrder: []string{"libs/proto", "libs/utils"},
+			expectedError: `workspace "services/api": dependency "libs/proto" failed`,
+		},
+		{
+			name:        "unknown dependency",
+			parallelism: 1,
+			dependencies: map[string][]string{
+				"services/api": {"libs/unknown"},
+			},
+			expectedError: `workspace "services/api" depends on unknown workspace "libs/unknown"`,
+		},
+		{
+			name:        "self dependency",
+			parallelism: 1,
+			dependencies: map[string][]string{
+				"services/api": {"services/api"},
+			},
+			expectedError: `workspace "services/api" depends on itself`,
+		},
+		{
+			name:        "dependency cycle",
+			parallelism: 1,
+			dependencies: map[string][]string{
+				"libs/a": {"libs/c"},
+				"libs/b": {"libs/a"},
+				"libs/c": {"libs/b"},
+			},
+			expectedError: "dependency cycle: libs/c -> libs/b -> libs/a -> libs/c",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mason := NewMason()
+			mason.Parallelism = tt.parallelism
+
+			var workspaces []Workspace
+			for path, dependencies := range tt.dependencies {
+				workspaces = append(workspaces, Workspace{
+					RelativePath: path,
+					Config:       WorkspaceConfig{DependsOn: dependencies},
+					mason:        mason,
+				})
+			}
+
+			var (
+				mu    sync.Mutex
+				order []string
+			)
+			err := mason.ForEachWorkspace(workspaces, func(w Workspace) error {
+				mu.Lock()
+				order = append(order, w.RelativePath)
+				mu.Unlock()
+				if tt.failing[w.RelativePath] {
+					return fmt.Errorf("%s failed", w.RelativePath)
+				}
+				return nil
+			})
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("expected error %q, got nil", tt.expectedError)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error to contain %q, got %v", tt.expectedError, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.parallelism > 1 {
+				// only the dependencies are guaranteed to run before their dependents
+				for _, path := range order {
+					for _, dependency := range tt.dependencies[path] {
+						if slices.Index(order, dependency) > slices.Index(order, path) {
+							t.Errorf("expected %q to run before %q, got %v", dependency, path, order)
+						}
+					}
+				}
+				slices.Sort(order)
+				tt.expectedOrder = slices.Sorted(slices.Values(tt.expectedOrder))
+			}
+			if !slices.Equal(order, tt.expectedOrder) {
+				t.Errorf("expected order %v, got %v", tt.expectedOrder, order)
+			}
+		})
+	}
+}
+
+func TestMasonDetectWorkspaces(t *testing.T) {
+	t.Parallel()
+
+	rootPath := t.TempDir()
+	writeFile(t, filepath.Join(rootPath, MasonDirName, "bricks.yaml"), "")
+	writeFile(t, filepath.Join(rootPath, "libs", "proto", MasonDirName, WorkspaceConfigFileName), "")
+	writeFile(t, filepath.Join(rootPath, "services", "api", MasonDirName, WorkspaceConfigFileName), "dependsOn: [libs/proto/]\n")
+	// nested in a workspace
+	writeFile(t, filepath.Join(rootPath, "services", "api", "cmd", MasonDirName, "bricks.yaml"), "")
+	// in a .mason directory, which is not walked
+	writeFile(t, filepath.Join(rootPath, "services", "api", MasonDirName, "templates", MasonDirName, "bricks.yaml"), "")
+	// in an ignored directory
+	writeFile(t, filepath.Join(rootPath, ".git", MasonDirName, "bricks.yaml"), "")
+	writeFile(t, filepath.Join(rootPath, "vendor", "lib", MasonDirName, "bricks.yaml"), "")
+
+	mason := NewMason()
+	mason.RootPath = rootPath
+	mason.IgnoredDirs = append(mason.IgnoredDirs, "vendor")
+	workspaces, err := mason.DetectWorkspaces()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var paths []string
+	for _, workspace := range workspaces {
+		paths = append(paths, workspace.RelativePath)
+	}
+	expectedPaths := []string{".", "libs/proto", "services/api", "services/api/cmd"}
+	if !slices.Equal(paths, expectedPaths) {
+		t.Fatalf("expected workspaces %v, got %v", expectedPaths, paths)
+	}
+	if dependsOn := workspaces[2].Config.DependsOn; !slices.Equal(dependsOn, []string{"libs/proto"}) {
+		t.Errorf("expected the config of services/api to be loaded, got dependsOn %v", dependsOn)
+	}
+	if workDirs := mason.WorkDirs(); len(workDirs) != len(expectedPaths) {
+		t.Errorf("expected %d work directories, got %v", len(expectedPaths), workDirs)
+	}
+}
+
+func TestMasonForEachWorkspaceWithSlowDependency(t *testing.T) {
+	t.Parallel()
+
+	mason := NewMason()
+	mason.Parallelism = 2
+	workspaces := []Workspace{
+		{RelativePath: "libs/slow", mason: mason},
+		{RelativePath: "libs/fast", mason: mason},
+		{RelativePath: "services/api", Config: WorkspaceConfig{DependsOn: []string{"libs/slow"}}, mason: mason},
+		{RelativePath: "services/worker", Config: WorkspaceConfig{DependsOn: []string{"libs/fast"}}, mason: mason},
+	}
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	err := mason.ForEachWorkspace(workspaces, func(w Workspace) error {
+		if w.RelativePath == "libs/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, w.RelativePath)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// services/worker only waits for libs/fast
+	expectedOrder := []string{"libs/fast", "services/worker", "libs/slow", "services/api"}
+	if !slices.Equal(order, expectedOrder) {
+		t.Errorf("expected order %v, got %v", expectedOrder, order)
+	}
+}
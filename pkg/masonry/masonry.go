@@ -5,6 +5,13 @@ const (
 	WorkDirPrefix      = ".work"
 	BlueprintDirPrefix = "blueprint"
 	PlanDirPrefix      = "plan"
+	SchemaDirPrefix    = "schema"
+	ProfilesDirName    = "profiles"
+	LockDirPrefix      = "lock"
+
+	WorkspaceConfigFileName = "config.yaml"
+	MasonIgnoreFileName     = ".masonignore"
+	LockFileName            = "mason.lock"
 )
 
 var Phases = map[string]string{
@@ -0,0 +1,171 @@
+package masonry
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// matrixExcludeKey is the key of the matrix listing the combinations to exclude.
+const matrixExcludeKey = "exclude"
+
+// expandMatrices replaces each document defining a brick with a metadata.matrix
+// by 1 document per combination of the matrix values - in the order of the sorted keys,
+// the values of the last key changing first - minus the excluded combinations.
+// The values of each combination are available to the templated values as .matrix.
+func (w Workspace) expandMatrices(documents []document) ([]document, error) {
+	var (
+		expanded []document
+		errs     error
+	)
+	for _, document := range documents {
+		if !document.hasMatrix() {
+			expanded = append(expanded, document)
+			continue
+		}
+
+		combinationDocuments, err := w.expandMatrix(document)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		expanded = append(expanded, combinationDocuments...)
+	}
+	if errs != nil {
+		return nil, fmt.Errorf("invalid blueprint: %w", errs)
+	}
+	return expanded, nil
+}
+
+// hasMatrix returns true if the document defines a brick with a metadata.matrix.
+func (d document) hasMatrix() bool {
+	metadata, _ := d.Fields["metadata"].(map[string]any)
+	_, ok := metadata["matrix"]
+	return d.isBrick() && ok
+}
+
+// expandMatrix returns 1 document per combination of the matrix of the document.
+func (w Workspace) expandMatrix(d document) ([]document, error) {
+	metadata, _ := d.Fields["metadata"].(map[string]any)
+	combinations, err := matrixCombinations(metadata["matrix"])
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid matrix: %w", d.Source, err)
+	}
+	w.logger().WithFields("file", d.Source.File, "document", d.Source.Document, "combinations", len(combinations)).
+		Debug("Expanding matrix")
+
+	// the fields are shared by the combinations, and not modified
+	metadata = maps.Clone(metadata)
+	delete(metadata, "matrix")
+	fields := maps.Clone(d.Fields)
+	fields["metadata"] = metadata
+	expanded := make([]document, 0, len(combinations))
+	for _, combination := range combinations {
+		combinationDocument := d
+		combinationDocument.Fields = fields
+		combinationDocument.Source.Matrix = combination
+		expanded = append(expanded, combinationDocument)
+	}
+	return expanded, nil
+}
+
+// matrixCombinations returns all the combinations of the values of the matrix, minus the excluded ones.
+func matrixCombinations(matrix any) ([]map[string]string, error) {
+	matrixMap, ok := matrix.(map[string]any)
+	if !ok {
+		return nil, errors.New("must be a map of lists of values")
+	}
+
+	var keys []string
+	values := make(map[string][]string)
+	for key, value := range matrixMap {
+		if key == matrixExcludeKey {
+			continue
+		}
+		list, ok := value.([]any)
+		if !ok || len(list) == 0 {
+			return nil, fmt.Errorf("%s must be a non-empty list of values", key)
+		}
+		for _, item := range list {
+			switch item.(type) {
+			case map[string]any, []any, nil:
+				return nil, fmt.Errorf("%s must only contain scalar values", key)
+			}
+			values[key] = append(values[key], fmt.Sprint(item))
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no values")
+	}
+	slices.Sort(keys)
+
+	excludes, err := matrixExcludes(matrixMap[matrixExcludeKey], values)
+	if err != nil {
+		return nil, err
+	}
+
+	combinations := []map[string]string{{}}
+	for _, key := range keys {
+		var next []map[string]string
+		for _, combination := range combinations {
+			for _, value := range values[key] {
+				nextCombination := maps.Clone(combination)
+				nextCombination[key] = value
+				next = append(next, nextCombination)
+			}
+		}
+		combinations = next
+	}
+
+	return slices.DeleteFunc(combinations, func(combination map[string]string) bool {
+		return slices.ContainsFunc(excludes, func(exclude map[string]string) bool {
+			for key, value := range exclude {
+				if combination[key] != value {
+					return false
+				}
+			}
+			return true
+		})
+	}), nil
+}
+
+// matrixExcludes returns the excluded partial combinations: a combination is excluded
+// if it has all the values of one of them.
+func matrixExcludes(value any, values map[string][]string) ([]map[string]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	list, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a list of combinations", matrixExcludeKey)
+	}
+
+	var excludes []map[string]string
+	for i, item := range list {
+		itemMap, ok := item.(map[string]any)
+		if !ok || len(itemMap) == 0 {
+			return nil, fmt.Errorf("%s[%d] must be a non-empty map of values", matrixExcludeKey, i)
+		}
+		exclude := make(map[string]string, len(itemMap))
+		for key, value := range itemMap {
+			if _, ok := values[key]; !ok {
+				return nil, fmt.Errorf("%s[%d]: unknown key %s", matrixExcludeKey, i, key)
+			}
+			exclude[key] = fmt.Sprint(value)
+		}
+		excludes = append(excludes, exclude)
+	}
+	return excludes, nil
+}
+
+// matrixString returns the values of a matrix combination, sorted by key.
+func matrixString(combination map[string]string) string {
+	var values []string
+	for _, key := range slices.Sorted(maps.Keys(combination)) {
+		values = append(values, key+"="+combination[key])
+	}
+	return strings.Join(values, " ")
+}
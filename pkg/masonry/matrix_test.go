@@ -0,0 +1,221 @@
+package masonry
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMatrixCombinations(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		matrix        any
+		expected      []map[string]string
+		expectedError string
+	}{
+		{
+			name:   "single key",
+			matrix: map[string]any{"os": []any{"linux", "darwin"}},
+			expected: []map[string]string{
+				{"os": "linux"},
+				{"os": "darwin"},
+			},
+		},
+		{
+			name: "multiple keys with excludes",
+			matrix: map[string]any{
+				"os":   []any{"linux", "darwin"},
+				"arch": []any{"amd64", "arm64"},
+				"exclude": []any{
+					map[string]any{"os": "darwin", "arch": "amd64"},
+				},
+			},
+			expected: []map[string]string{
+				{"arch": "amd64", "os": "linux"},
+				{"arch": "arm64", "os": "linux"},
+				{"arch": "arm64", "os": "darwin"},
+			},
+		},
+		{
+			name: "partial exclude and scalar values",
+			matrix: map[string]any{
+				"go":  []any{1.24, 1.25},
+				"cgo": []any{true, false},
+				"exclude": []any{
+					map[string]any{"cgo": true},
+				},
+			},
+			expected: []map[string]string{
+				{"cgo": "false", "go": "1.24"},
+				{"cgo": "false", "go": "1.25"},
+			},
+		},
+		{
+			name:          "not a map",
+			matrix:        []any{"linux"},
+			expectedError: "must be a map of lists of values",
+		},
+		{
+			name:          "empty list",
+			matrix:        map[string]any{"os": []any{}},
+			expectedError: "os must be a non-empty list of values",
+		},
+		{
+			name:          "nested values",
+			matrix:        map[string]any{"os": []any{map[string]any{"name": "linux"}}},
+			expectedError: "os must only contain scalar values",
+		},
+		{
+			name:          "only excludes",
+			matrix:        map[string]any{"exclude": []any{map[string]any{"os": "linux"}}},
+			expectedError: "no values",
+		},
+		{
+			name: "unknown exclude key",
+			matrix: map[string]any{
+				"os":      []any{"linux"},
+				"exclude": []any{map[string]any{"arch": "amd64"}},
+			},
+			expectedError: "exclude[0]: unknown key arch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := matrixCombinations(tt.matrix)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(actual, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWorkspaceLoadBlueprintWithMatrix(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		content         string
+		expectedBricks  []string
+		expectedSources []string
+		expectedSpecs   []any
+		expectedError   string
+	}{
+		{
+			name: "expanded bricks",
+			content: `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: mason-{{ .matrix.os }}-{{ .matrix.arch }}
+  labels:
+    os: "{{ .matrix.os }}"
+  when: ne .matrix.os "windows"
+  matrix:
+    os: [linux, darwin, windows]
+    arch: [amd64, arm64]
+    exclude:
+      - os: darwin
+        arch: amd64
+spec:
+  output:
+    hostFilePath: bin/mason-{{ .matrix.os }}-{{ .matrix.arch }}
+`,
+			expectedBricks: []string{"GoBinary/mason-linux-amd64", "GoBinary/mason-linux-arm64", "GoBinary/mason-darwin-arm64"},
+			expectedSources: []string{
+				"bricks.yaml (document 0, matrix arch=amd64 os=linux)",
+				"bricks.yaml (document 0, matrix arch=arm64 os=linux)",
+				"bricks.yaml (document 0, matrix arch=arm64 os=darwin)",
+			},
+			expectedSpecs: []any{
+				map[string]any{"output": map[string]any{"hostFilePath": "bin/mason-linux-amd64"}},
+				map[string]any{"output": map[string]any{"hostFilePath": "bin/mason-linux-arm64"}},
+				map[string]any{"output": map[string]any{"hostFilePath": "bin/mason-darwin-arm64"}},
+			},
+		},
+		{
+			name: "name without matrix values",
+			content: `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: mason
+  matrix:
+    os: [linux, darwin]
+`,
+			expectedError: "bricks.yaml (document 0, matrix os=darwin): duplicate brick GoBinary mason, already defined in bricks.yaml (document 0, matrix os=linux)",
+		},
+		{
+			name: "invalid matrix",
+			content: `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: mason
+  matrix: [linux, darwin]
+`,
+			expectedError: "bricks.yaml (document 0): invalid matrix: must be a map of lists of values",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rootPath := t.TempDir()
+			masonDir := filepath.Join(rootPath, MasonDirName)
+			if err := os.MkdirAll(masonDir, os.ModePerm); err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+			writeFile(t, filepath.Join(masonDir, "bricks.yaml"), tt.content)
+			workspace := Workspace{
+				RootPath:     rootPath,
+				RelativePath: ".",
+				mason:        NewMason(),
+			}
+
+			blueprint, err := workspace.LoadBlueprint()
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var bricks, sources []string
+			var specs []any
+			for _, brick := range blueprint.Bricks {
+				bricks = append(bricks, brick.Kind+"/"+brick.Metadata.Name)
+				sources = append(sources, brick.Source.String())
+				specs = append(specs, brick.Spec)
+				if brick.Metadata.Labels["os"] != brick.Source.Matrix["os"] {
+					t.Errorf("expected os label %s, got %s", brick.Source.Matrix["os"], brick.Metadata.Labels["os"])
+				}
+			}
+			if !slices.Equal(bricks, tt.expectedBricks) {
+				t.Errorf("expected bricks %v, got %v", tt.expectedBricks, bricks)
+			}
+			if !slices.Equal(sources, tt.expectedSources) {
+				t.Errorf("expected sources %v, got %v", tt.expectedSources, sources)
+			}
+			if !reflect.DeepEqual(specs, tt.expectedSpecs) {
+				t.Errorf("expected specs %v, got %v", tt.expectedSpecs, specs)
+			}
+		})
+	}
+}
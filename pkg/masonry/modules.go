@@ -0,0 +1,329 @@
+package masonry
+
+import (
+	"cmp"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"golang.org/x/mod/semver"
+)
+
+// Base returns the module reference without its version.
+func (m ModuleRef) Base() ModuleRef {
+	ref := string(m)
+	if i := strings.LastIndex(ref, "@"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ModuleRef(ref)
+}
+
+// ModuleUsage is a module used by a blueprint, with the bricks using it.
+type ModuleUsage struct {
+	ModuleRef ModuleRef
+	Aliases   []string      // the aliases of the module in the workspace config
+	Locked    *LockedModule // the module pinned in the lock file, if any
+	Bricks    []Brick
+}
+
+// ModuleUsages returns the modules used by the blueprint, sorted by reference.
+func (b Blueprint) ModuleUsages() []ModuleUsage {
+	var usages []ModuleUsage
+	for moduleRef, blueprint := range b.splitByModuleRef() {
+		usage := ModuleUsage{
+			ModuleRef: moduleRef,
+			Bricks:    blueprint.Bricks,
+		}
+		for alias, aliasModuleRef := range b.workspace.Config.Modules {
+			if aliasModuleRef == moduleRef {
+				usage.Aliases = append(usage.Aliases, alias)
+			}
+		}
+		slices.Sort(usage.Aliases)
+		if lockedModule, ok := b.workspace.Lock.Modules[moduleRef]; ok {
+			usage.Locked = &lockedModule
+		}
+		usages = append(usages, usage)
+	}
+	slices.SortFunc(usages, func(a, b ModuleUsage) int {
+		return cmp.Compare(a.ModuleRef, b.ModuleRef)
+	})
+	return usages
+}
+
+// ModuleRefUpdate is a module reference updated in a file of the .mason directory.
+type ModuleRefUpdate struct {
+	File string // relative to the .mason directory
+	Line int
+	From ModuleRef
+	To   ModuleRef
+}
+
+// UpgradeModules rewrites the references of the matching modules in the YAML and JSON files of the .mason directory:
+// the moduleRef fields of the bricks, and the module aliases of the workspace config.
+// The module is either an alias, or a module reference - with or without its version. If empty, all the modules match.
+// The version function returns the new version of a module, given its reference without version.
+// The files are edited in place, so that their formatting, comments and anchors are preserved.
+func (w Workspace) UpgradeModules(module string, version func(ModuleRef) (string, error)) ([]ModuleRefUpdate, error) {
+	var moduleBase ModuleRef
+	if module != "" {
+		moduleBase = w.Config.ResolveModuleRef(ModuleRef(module)).Base()
+	}
+	versions := make(map[ModuleRef]string) // by module base
+
+	var updates []ModuleRefUpdate
+	contents := make(map[string][]byte) // by file path - written once all the files are parsed
+	err := filepath.WalkDir(w.MasonDir(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(w.MasonDir(), path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path of %s: %w", path, err)
+		}
+		relativePath = filepath.ToSlash(relativePath)
+		if entry.IsDir() {
+			if relativePath == WorkDirPrefix {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(entry.Name()) {
+		case ".json", ".yaml", ".yml":
+			// valid file types
+		default:
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", relativePath, err)
+		}
+		refs, err := findModuleRefs(content, relativePath == WorkspaceConfigFileName)
+		if err != nil {
+			return fmt.Errorf("failed to parse file %s: %w", relativePath, err)
+		}
+
+		var fileUpdates []ModuleRefUpdate
+		for _, ref := range refs {
+			base := ref.value.Base()
+			if ref.value == base && w.Config.Modules[string(ref.value)] != "" {
+				continue // an alias, updated with the workspace config
+			}
+			if moduleBase != "" && base != moduleBase {
+				continue
+			}
+			newVersion, ok := versions[base]
+			if !ok {
+				newVersion, err = version(base)
+				if err != nil {
+					return fmt.Errorf("failed to get the new version of module %s: %w", base, err)
+				}
+				versions[base] = newVersion
+			}
+			newRef := ModuleRef(string(base) + "@" + newVersion)
+			if newRef == ref.value {
+				continue
+			}
+			content = ref.replace(content, string(newRef))
+			fileUpdates = append(fileUpdates, ModuleRefUpdate{
+				File: relativePath,
+				Line: ref.line,
+				From: ref.value,
+				To:   newRef,
+			})
+		}
+		if len(fileUpdates) > 0 {
+			contents[path] = content
+			updates = append(updates, fileUpdates...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade modules in %s: %w", w.MasonDir(), err)
+	}
+
+	for path, content := range contents {
+		err = os.WriteFile(path, content, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write file %s: %w", path, err)
+		}
+	}
+	slices.SortFunc(updates, func(a, b ModuleRefUpdate) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line))
+	})
+	return updates, nil
+}
+
+// moduleRefNode is a module reference found in a YAML or JSON file.
+type moduleRefNode struct {
+	value  ModuleRef
+	line   int // starting at 1
+	column int // starting at 1
+}
+
+// findModuleRefs returns the values of the top-level moduleRef fields - of the bricks -
+// and the values of the top-level "modules" map for the workspace config file,
+// in reverse order of position - so that they can be replaced without shifting the next ones.
+func findModuleRefs(content []byte, isConfig bool) ([]moduleRefNode, error) {
+	file, err := parser.ParseBytes(content, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []ast.Node
+	for _, doc := range file.Docs {
+		for _, mappingValue := range topLevelMappingValues(doc) {
+			switch mappingValue.Key.GetToken().Value {
+			case "moduleRef":
+				nodes = append(nodes, mappingValue.Value)
+			case "modules":
+				if !isConfig {
+					continue
+				}
+				for _, module := range ast.Filter(ast.MappingValueType, mappingValue.Value) {
+					nodes = append(nodes, module.(*ast.MappingValueNode).Value) //nolint:forcetypeassert // filtered by type
+				}
+			}
+		}
+	}
+
+	var refs []moduleRefNode
+	for _, node := range nodes {
+		if anchor, ok := node.(*ast.AnchorNode); ok {
+			node = anchor.Value
+		}
+		stringNode, ok := node.(*ast.StringNode)
+		if !ok {
+			continue // aliases are updated with their anchor
+		}
+		refs = append(refs, moduleRefNode{
+			value:  ModuleRef(stringNode.Value),
+			line:   stringNode.GetToken().Position.Line,
+			column: stringNode.GetToken().Position.Column,
+		})
+	}
+	slices.SortFunc(refs, func(a, b moduleRefNode) int {
+		return cmp.Or(cmp.Compare(b.line, a.line), cmp.Compare(b.column, a.column))
+	})
+	return slices.CompactFunc(refs, func(a, b moduleRefNode) bool {
+		return a.line == b.line && a.column == b.column
+	}), nil
+}
+
+// topLevelMappingValues returns the fields of a YAML document, if it is a map.
+func topLevelMappingValues(doc *ast.DocumentNode) []*ast.MappingValueNode {
+	switch body := doc.Body.(type) {
+	case *ast.MappingNode:
+		return body.Values
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{body}
+	default:
+		return nil
+	}
+}
+
+// replace returns the content with the node value replaced, keeping its quoting style.
+func (n moduleRefNode) replace(content []byte, value string) []byte {
+	start := 0
+	for line := 1; line < n.line; line++ {
+		start += strings.IndexByte(string(content[start:]), '\n') + 1
+	}
+	start += n.column - 1
+
+	var end int
+	var newValue string
+	switch content[start] {
+	case '"':
+		end = start + 1
+		for end < len(content) && content[end] != '"' {
+			if content[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		end++
+		newValue = strconv.Quote(value)
+	case '\'':
+		end = start + 1 + strings.Index(string(content[start+1:]), "'") + 1
+		newValue = "'" + value + "'"
+	default:
+		end = start + len(n.value)
+		newValue = value
+	}
+
+	updated := slices.Clone(content[:start])
+	updated = append(updated, newValue...)
+	return append(updated, content[end:]...)
+}
+
+// LatestModuleVersion returns the latest semver tag of the git repository of a module, with the git CLI.
+// The tags prefixed by the module path in the repository - such as "golang/v1.2.3" - are preferred.
+// The prerelease versions - such as "v1.2.3-rc.1" - are only considered if prerelease is set.
+func LatestModuleVersion(moduleRef ModuleRef, prerelease bool) (string, error) {
+	base := strings.TrimPrefix(strings.TrimPrefix(string(moduleRef.Base()), "https://"), "http://")
+	parts := strings.Split(strings.TrimSuffix(base, "/"), "/")
+	// the repository is a prefix of the module reference, with at least a host and a path
+	for i := 2; i <= len(parts); i++ {
+		repository := strings.Join(parts[:i], "/")
+		url := repository
+		if !strings.Contains(url, "@") {
+			url = "https://" + url
+		}
+		output, err := runGit(".", "ls-remote", "--tags", "--refs", url)
+		if err != nil {
+			continue
+		}
+		var tags []string
+		for _, line := range strings.Split(output, "\n") {
+			if _, ref, ok := strings.Cut(strings.TrimSpace(line), "refs/tags/"); ok {
+				tags = append(tags, ref)
+			}
+		}
+		version, ok := latestVersion(tags, strings.Join(parts[i:], "/"), prerelease)
+		if !ok && !prerelease {
+			return "", fmt.Errorf("no stable semver tag found in repository %s", url)
+		}
+		if !ok {
+			return "", fmt.Errorf("no semver tag found in repository %s", url)
+		}
+		return version, nil
+	}
+	return "", fmt.Errorf("no git repository found for module %s", moduleRef)
+}
+
+// latestVersion returns the latest semver version of the tags,
+// preferring the tags prefixed by the module path - which is removed.
+// The prerelease versions are ignored, unless prerelease is set.
+func latestVersion(tags []string, modulePath string, prerelease bool) (string, bool) {
+	isValid := func(version string) bool {
+		return semver.IsValid(version) && (prerelease || semver.Prerelease(version) == "")
+	}
+	var versions, prefixedVersions []string
+	for _, tag := range tags {
+		if modulePath != "" {
+			if version, ok := strings.CutPrefix(tag, modulePath+"/"); ok && semver.IsValid(version) {
+				if isValid(version) {
+					prefixedVersions = append(prefixedVersions, version)
+				}
+				continue
+			}
+		}
+		if isValid(tag) {
+			versions = append(versions, tag)
+		}
+	}
+	if len(prefixedVersions) > 0 {
+		versions = prefixedVersions
+	}
+	if len(versions) == 0 {
+		return "", false
+	}
+	return slices.MaxFunc(versions, semver.Compare), true
+}
@@ -0,0 +1,228 @@
+package masonry
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWorkspaceUpgradeModules(t *testing.T) {
+	t.Parallel()
+
+	const (
+		config = `# module aliases
+modules:
+  golang: github.com/vbehar/mason-modules/golang@v0.0.5 # the Go module
+  run: github.com/vbehar/mason-modules/run@v0.0.5
+`
+		bricks = `# Go bricks
+kind: GoBinary
+moduleRef: golang
+metadata:
+  name: mason
+---
+kind: GoTest
+moduleRef: &golang "github.com/vbehar/mason-modules/golang@v0.0.4" # an older version
+metadata:
+  name: unit-tests
+---
+kind: GoLint
+moduleRef: *golang
+metadata: {name: lint}
+---
+kind: RunBinary
+moduleRef: 'github.com/vbehar/mason-modules/run'
+metadata:
+  name: version
+spec:
+  moduleRef: github.com/vbehar/mason-modules/run@v0.0.1 # not a brick field
+`
+	)
+
+	tests := []struct {
+		name            string
+		module          string
+		expectedUpdates []ModuleRefUpdate
+		expectedConfig  string
+		expectedBricks  string
+	}{
+		{
+			name:   "alias",
+			module: "golang",
+			expectedUpdates: []ModuleRefUpdate{
+				{File: "bricks.yaml", Line: 8, From: "github.com/vbehar/mason-modules/golang@v0.0.4", To: "github.com/vbehar/mason-modules/golang@v1.0.0"},
+				{File: "config.yaml", Line: 3, From: "github.com/vbehar/mason-modules/golang@v0.0.5", To: "github.com/vbehar/mason-modules/golang@v1.0.0"},
+			},
+			expectedConfig: `# module aliases
+modules:
+  golang: github.com/vbehar/mason-modules/golang@v1.0.0 # the Go module
+  run: github.com/vbehar/mason-modules/run@v0.0.5
+`,
+			expectedBricks: `# Go bricks
+kind: GoBinary
+moduleRef: golang
+metadata:
+  name: mason
+---
+kind: GoTest
+moduleRef: &golang "github.com/vbehar/mason-modules/golang@v1.0.0" # an older version
+metadata:
+  name: unit-tests
+---
+kind: GoLint
+moduleRef: *golang
+metadata: {name: lint}
+---
+kind: RunBinary
+moduleRef: 'github.com/vbehar/mason-modules/run'
+metadata:
+  name: version
+spec:
+  moduleRef: github.com/vbehar/mason-modules/run@v0.0.1 # not a brick field
+`,
+		},
+		{
+			name:   "module reference without version",
+			module: "github.com/vbehar/mason-modules/run",
+			expectedUpdates: []ModuleRefUpdate{
+				{File: "bricks.yaml", Line: 17, From: "github.com/vbehar/mason-modules/run", To: "github.com/vbehar/mason-modules/run@v1.0.0"},
+				{File: "config.yaml", Line: 4, From: "github.com/vbehar/mason-modules/run@v0.0.5", To: "github.com/vbehar/mason-modules/run@v1.0.0"},
+			},
+			expectedConfig: `# module aliases
+modules:
+  golang: github.com/vbehar/mason-modules/golang@v0.0.5 # the Go module
+  run: github.com/vbehar/mason-modules/run@v1.0.0
+`,
+			expectedBricks: `# Go bricks
+kind: GoBinary
+moduleRef: golang
+metadata:
+  name: mason
+---
+kind: GoTest
+moduleRef: &golang "github.com/vbehar/mason-modules/golang@v0.0.4" # an older version
+metadata:
+  name: unit-tests
+---
+kind: GoLint
+moduleRef: *golang
+metadata: {name: lint}
+---
+kind: RunBinary
+moduleRef: 'github.com/vbehar/mason-modules/run@v1.0.0'
+metadata:
+  name: version
+spec:
+  moduleRef: github.com/vbehar/mason-modules/run@v0.0.1 # not a brick field
+`,
+		},
+		{
+			name:           "unknown module",
+			module:         "github.com/vbehar/mason-modules/unknown",
+			expectedConfig: config,
+			expectedBricks: bricks,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rootPath := t.TempDir()
+			masonDir := filepath.Join(rootPath, MasonDirName)
+			writeFile(t, filepath.Join(masonDir, WorkspaceConfigFileName), config)
+			writeFile(t, filepath.Join(masonDir, "bricks.yaml"), bricks)
+			workspace := Workspace{
+				RootPath:     rootPath,
+				RelativePath: ".",
+				Config: WorkspaceConfig{Modules: map[string]ModuleRef{
+					"golang": "github.com/vbehar/mason-modules/golang@v0.0.5",
+					"run":    "github.com/vbehar/mason-modules/run@v0.0.5",
+				}},
+				mason: NewMason(),
+			}
+
+			updates, err := workspace.UpgradeModules(tt.module, func(ModuleRef) (string, error) {
+				return "v1.0.0", nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(updates, tt.expectedUpdates) {
+				t.Errorf("expected updates %v, got %v", tt.expectedUpdates, updates)
+			}
+			for file, expected := range map[string]string{
+				WorkspaceConfigFileName: tt.expectedConfig,
+				"bricks.yaml":           tt.expectedBricks,
+			} {
+				actual, err := os.ReadFile(filepath.Join(masonDir, file))
+				if err != nil {
+					t.Fatalf("failed to read file %s: %v", file, err)
+				}
+				if string(actual) != expected {
+					t.Errorf("expected %s:\n%s\ngot:\n%s", file, expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		tags            []string
+		modulePath      string
+		prerelease      bool
+		expectedVersion string
+		expectedOk      bool
+	}{
+		{
+			name:            "semver tags",
+			tags:            []string{"v0.0.5", "v0.0.10", "latest", "v0.1.0-rc.1"},
+			expectedVersion: "v0.0.10",
+			expectedOk:      true,
+		},
+		{
+			name:            "prerelease tags",
+			tags:            []string{"v0.0.5", "v0.0.10", "latest", "v0.1.0-rc.1"},
+			prerelease:      true,
+			expectedVersion: "v0.1.0-rc.1",
+			expectedOk:      true,
+		},
+		{
+			name: "only prerelease tags",
+			tags: []string{"v0.1.0-rc.1", "v0.1.0-rc.2"},
+		},
+		{
+			name:            "module path tags preferred",
+			tags:            []string{"v2.0.0", "golang/v0.0.6", "run/v0.0.7", "golang/v0.0.5"},
+			modulePath:      "golang",
+			expectedVersion: "v0.0.6",
+			expectedOk:      true,
+		},
+		{
+			name:            "no module path tags",
+			tags:            []string{"v0.0.5", "run/v0.0.7"},
+			modulePath:      "golang",
+			expectedVersion: "v0.0.5",
+			expectedOk:      true,
+		},
+		{
+			name: "no semver tags",
+			tags: []string{"latest", "1.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			version, ok := latestVersion(tt.tags, tt.modulePath, tt.prerelease)
+			if version != tt.expectedVersion || ok != tt.expectedOk {
+				t.Errorf("expected %q (%v), got %q (%v)", tt.expectedVersion, tt.expectedOk, version, ok)
+			}
+		})
+	}
+}
@@ -23,13 +23,34 @@ type Plan struct {
 	MergedScript           string
 	PostRunOnSuccessScript string
 	PostRunOnFailureScript string
+	IsolatedScripts        []IsolatedScript // run after the merged script
 
 	blueprint Blueprint
 }
 
 func ParsePlanFromDir(dirPath string) (*Plan, error) {
+	return parsePlanFromDir(dirPath, Blueprint{})
+}
+
+// parsePlanFromDir parses the scripts of a plan directory, which contains 1 directory per module.
+// A module directory contains either the scripts of all its bricks,
+// or 1 directory per brick - named after its ID - with the scripts of the brick.
+// The scripts of a brick depend on the scripts of the bricks listed in its metadata.dependsOn.
+func parsePlanFromDir(dirPath string, blueprint Blueprint) (*Plan, error) {
 	plan := Plan{
-		DirPath: dirPath,
+		DirPath:   dirPath,
+		blueprint: blueprint,
+	}
+
+	dependencies := make(map[string][]string) // brick ID -> IDs of its dependencies
+	for _, brick := range blueprint.Bricks {
+		// the dependencies have been checked when loading the blueprint:
+		// the bricks which are not part of the plan - filtered out - are ignored
+		ids, err := brickDependencies(brick, blueprint.Bricks, func(string) bool { return true })
+		if err != nil {
+			return nil, fmt.Errorf("invalid brick %s %s: %w", brick.Kind, brick.Metadata.Name, err)
+		}
+		dependencies[brick.ID()] = ids
 	}
 
 	entries, err := os.ReadDir(dirPath)
@@ -42,26 +63,33 @@ func ParsePlanFromDir(dirPath string) (*Plan, error) {
 			continue
 		}
 
-		subDirPath := filepath.Join(dirPath, entry.Name())
-		files, err := os.ReadDir(subDirPath)
+		moduleName := entry.Name()
+		moduleDirPath := filepath.Join(dirPath, moduleName)
+		scripts, err := parseScriptsFromDir(moduleDirPath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read directory %s: %w", subDirPath, err)
+			return nil, err
+		}
+		plan.SourceScripts = append(plan.SourceScripts, scripts...)
+
+		files, err := os.ReadDir(moduleDirPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory %s: %w", moduleDirPath, err)
 		}
 		for _, file := range files {
-			if file.IsDir() {
-				continue
-			}
-			if filepath.Ext(file.Name()) != ".dagger" {
+			if !file.IsDir() {
 				continue
 			}
-
-			filePath := filepath.Join(dirPath, entry.Name(), file.Name())
-			script, err := ScriptFromFile(filePath)
+			brickID := file.Name()
+			scripts, err := parseScriptsFromDir(filepath.Join(moduleDirPath, brickID))
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse script from file %s: %w", filePath, err)
+				return nil, err
+			}
+			for _, script := range scripts {
+				script.ModuleName = moduleName
+				script.Brick = brickID
+				script.DependsOn = dependencies[brickID]
+				plan.SourceScripts = append(plan.SourceScripts, script)
 			}
-
-			plan.SourceScripts = append(plan.SourceScripts, *script)
 		}
 	}
 
@@ -73,8 +101,34 @@ func ParsePlanFromDir(dirPath string) (*Plan, error) {
 	return &plan, nil
 }
 
+// parseScriptsFromDir parses the scripts of a directory - not recursively.
+func parseScriptsFromDir(dirPath string) ([]Script, error) {
+	files, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dirPath, err)
+	}
+
+	var scripts []Script
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if filepath.Ext(file.Name()) != ".dagger" {
+			continue
+		}
+
+		filePath := filepath.Join(dirPath, file.Name())
+		script, err := ScriptFromFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse script from file %s: %w", filePath, err)
+		}
+		scripts = append(scripts, *script)
+	}
+	return scripts, nil
+}
+
 func (p Plan) IsEmpty() bool {
-	return len(p.SourceScripts) == 0 || p.MergedScript == ""
+	return len(p.SourceScripts) == 0 || (p.MergedScript == "" && len(p.IsolatedScripts) == 0)
 }
 
 func (p Plan) FilterForPhase(phase string) (*Plan, error) {
@@ -113,6 +167,12 @@ func (p *Plan) computeFinalScripts() error {
 			mainScripts = append(mainScripts, script)
 		}
 	}
+	mainScripts, isolatedScripts, err := p.isolateScripts(mainScripts)
+	if err != nil {
+		return fmt.Errorf("failed to isolate the scripts of the bricks with a timeout or retries: %w", err)
+	}
+	p.IsolatedScripts = isolatedScripts
+
 	p.MergedScript = ""
 	if len(mainScripts) > 0 {
 		mainScript, err := mergeScripts(mainScripts)
@@ -175,10 +235,45 @@ func (p *Plan) computeFinalScripts() error {
 
 func (p Plan) Run() error {
 	p.blueprint.workspace.mason.EventBus.Publish(partybus.Event{
-		Type:   EventTypeApplyPlan,
-		Source: map[string]string{"phase": p.Phase},
+		Type: EventTypeApplyPlan,
+		Source: map[string]string{
+			"workspace": p.blueprint.workspace.RelativePath,
+			"phase":     p.Phase,
+		},
 	})
 
+	var runErr error
+	if p.MergedScript != "" {
+		runErr = p.runMainScript()
+	}
+	for _, isolated := range p.IsolatedScripts {
+		if runErr != nil {
+			break
+		}
+		runErr = p.runIsolatedScript(isolated)
+	}
+
+	postRun := PostRunOnSuccess
+	if runErr != nil {
+		postRun = PostRunOnFailure
+	}
+	postRunErr := p.runPostScript(postRun)
+	if postRunErr != nil {
+		if runErr != nil {
+			runErr = errors.Join(runErr, postRunErr)
+		} else {
+			runErr = postRunErr
+		}
+	}
+
+	if runErr != nil {
+		return fmt.Errorf("failed to run plan: %w", runErr)
+	}
+	return nil
+}
+
+// runMainScript runs the merged script of the plan.
+func (p Plan) runMainScript() error {
 	planFileName := fmt.Sprintf("plan_%s.dagger", p.Phase)
 	planFilePath := filepath.Join(p.DirPath, planFileName)
 	p.logger().WithFields("path", planFilePath).
@@ -190,6 +285,11 @@ func (p Plan) Run() error {
 		return fmt.Errorf("failed to write plan file %q: %w", planFilePath, err)
 	}
 
+	secrets, err := p.blueprint.workspace.resolveSecrets()
+	if err != nil {
+		return err
+	}
+
 	logFile, err := os.Create(p.logFilePath())
 	if err != nil {
 		return fmt.Errorf("failed to create log file %q: %w", p.logFilePath(), err)
@@ -208,9 +308,11 @@ func (p Plan) Run() error {
 		BinaryPath:    p.blueprint.workspace.mason.DaggerBinary,
 		Logger:        p.logger(),
 		ScriptPath:    planFilePath,
-		Env:           p.blueprint.workspace.mason.DaggerEnv,
+		Dir:           p.blueprint.workspace.Dir(),
+		Env:           secrets.daggerEnv,
 		Args:          p.blueprint.workspace.mason.DaggerArgs,
 		DisableOutput: p.blueprint.workspace.mason.DaggerOutputDisabled,
+		Secrets:       secrets.values,
 		Stdout:        &daggerOutWriter,
 		Stderr:        logFile,
 	})
@@ -223,29 +325,15 @@ func (p Plan) Run() error {
 	)
 	if output != "" {
 		p.blueprint.workspace.mason.EventBus.Publish(partybus.Event{
-			Type:   EventTypeDaggerOutput,
-			Source: map[string]string{"phase": p.Phase},
-			Value:  output,
+			Type: EventTypeDaggerOutput,
+			Source: map[string]string{
+				"workspace": p.blueprint.workspace.RelativePath,
+				"phase":     p.Phase,
+			},
+			Value: output,
 		})
 	}
-
-	postRun := PostRunOnSuccess
-	if runErr != nil {
-		postRun = PostRunOnFailure
-	}
-	postRunErr := p.runPostScript(postRun)
-	if postRunErr != nil {
-		if runErr != nil {
-			runErr = errors.Join(runErr, postRunErr)
-		} else {
-			runErr = postRunErr
-		}
-	}
-
-	if runErr != nil {
-		return fmt.Errorf("failed to run plan: %w", runErr)
-	}
-	return nil
+	return runErr
 }
 
 func (p Plan) runPostScript(postRun PostRun) error {
@@ -265,8 +353,12 @@ func (p Plan) runPostScript(postRun PostRun) error {
 	}
 
 	p.blueprint.workspace.mason.EventBus.Publish(partybus.Event{
-		Type:   EventTypeApplyPlan,
-		Source: map[string]string{"phase": p.Phase, "postRun": string(postRun)},
+		Type: EventTypeApplyPlan,
+		Source: map[string]string{
+			"workspace": p.blueprint.workspace.RelativePath,
+			"phase":     p.Phase,
+			"postRun":   string(postRun),
+		},
 	})
 
 	planFileName := fmt.Sprintf("plan_%s_postrun_%s.dagger", p.Phase, postRun)
@@ -280,6 +372,11 @@ func (p Plan) runPostScript(postRun PostRun) error {
 		return fmt.Errorf("failed to write post-run plan file %q: %w", planFilePath, err)
 	}
 
+	secrets, err := p.blueprint.workspace.resolveSecrets()
+	if err != nil {
+		return err
+	}
+
 	logFileName := fmt.Sprintf("dagger_%s_postrun_%s.log", p.Phase, postRun)
 	logFilePath := filepath.Join(p.DirPath, logFileName)
 	logFile, err := os.Create(logFilePath)
@@ -300,9 +397,11 @@ func (p Plan) runPostScript(postRun PostRun) error {
 		BinaryPath:    p.blueprint.workspace.mason.DaggerBinary,
 		Logger:        p.logger(),
 		ScriptPath:    planFilePath,
-		Env:           p.blueprint.workspace.mason.DaggerEnv,
+		Dir:           p.blueprint.workspace.Dir(),
+		Env:           secrets.daggerEnv,
 		Args:          p.blueprint.workspace.mason.DaggerArgs,
 		DisableOutput: p.blueprint.workspace.mason.DaggerOutputDisabled,
+		Secrets:       secrets.values,
 		Stdout:        &daggerOutWriter,
 		Stderr:        logFile,
 	})
@@ -315,9 +414,13 @@ func (p Plan) runPostScript(postRun PostRun) error {
 	)
 	if output != "" {
 		p.blueprint.workspace.mason.EventBus.Publish(partybus.Event{
-			Type:   EventTypeDaggerOutput,
-			Source: map[string]string{"phase": p.Phase, "postRun": string(postRun)},
-			Value:  output,
+			Type: EventTypeDaggerOutput,
+			Source: map[string]string{
+				"workspace": p.blueprint.workspace.RelativePath,
+				"phase":     p.Phase,
+				"postRun":   string(postRun),
+			},
+			Value: output,
 		})
 	}
 
@@ -368,23 +471,63 @@ func mergeScripts(scripts []Script) (string, error) {
 		return "", nil
 	}
 
+	scriptsDAG, err := newScriptsDAG(scripts)
+	if err != nil {
+		return "", err
+	}
+
+	var mergedScript string
+	scriptsDAG.DFSWalk(dagVisitorFunc(func(v dag.Vertexer) {
+		id, val := v.Vertex()
+		script, ok := val.(*Script)
+		if !ok {
+			err = errors.Join(err, fmt.Errorf("failed to cast vertex %q to Script", id))
+			return
+		}
+		mergedScript += fmt.Sprintf("# %s\n", script.Name)
+		mergedScript += strings.TrimSpace(string(script.Content)) + "\n"
+		mergedScript += ".echo\n\n" // we echo an empty line to separate scripts output
+	}))
+	mergedScript = strings.TrimSpace(mergedScript)
+
+	return mergedScript, err
+}
+
+// scriptsDAG is a DAG of scripts, ordered by the variables they define and use,
+// and by the dependencies of the bricks which produced them.
+// The ID of each vertex is the content of the script.
+type scriptsDAG struct {
+	*dag.DAG
+
+	// variables linking the scripts, indexed by the IDs of the source and destination vertices
+	edgesVariables map[[2]string][]string
+	// edges from the scripts of a brick to the scripts of the bricks depending on it
+	dependencyEdges map[[2]string]bool
+}
+
+func newScriptsDAG(scripts []Script) (*scriptsDAG, error) {
 	var (
-		variablesDAG         = dag.NewDAG()
+		variablesDAG = &scriptsDAG{
+			DAG:             dag.NewDAG(),
+			edgesVariables:  make(map[[2]string][]string),
+			dependencyEdges: make(map[[2]string]bool),
+		}
 		variablesDefinitions = make(map[string]Script)
 		variablesUsages      = make(map[string][]Script)
+		scriptsByBrick       = make(map[string][]Script)
 	)
 	for _, script := range scripts {
-		err := variablesDAG.AddVertexByID(string(script.Content), script)
+		err := variablesDAG.AddVertexByID(string(script.Content), &script)
 		if err != nil {
 			if errors.As(err, &dag.IDDuplicateError{}) {
 				continue // can happen if the same script is used in multiple phases...
 			}
-			return "", fmt.Errorf("failed to add script from %q to DAG: %w", script.Name, err)
+			return nil, fmt.Errorf("failed to add script from %q to DAG: %w", script.Name, err)
 		}
 
 		for varName := range script.Content.ExtractDefinedVariables() {
 			if existingScript, ok := variablesDefinitions[varName]; ok {
-				return "", fmt.Errorf("variable %q is defined twice: by %q and %q", varName, existingScript.Name, script.Name)
+				return nil, fmt.Errorf("variable %q is defined twice: by %q and %q", varName, existingScript.Name, script.Name)
 			}
 			variablesDefinitions[varName] = script
 		}
@@ -392,6 +535,10 @@ func mergeScripts(scripts []Script) (string, error) {
 		for varName := range script.Content.ExtractUsedVariables() {
 			variablesUsages[varName] = append(variablesUsages[varName], script)
 		}
+
+		if script.Brick != "" {
+			scriptsByBrick[script.Brick] = append(scriptsByBrick[script.Brick], script)
+		}
 	}
 
 	for varName, scripts := range variablesUsages {
@@ -405,32 +552,38 @@ func mergeScripts(scripts []Script) (string, error) {
 			if script.Equals(varDefinitionScript) {
 				continue
 			}
-			err := variablesDAG.AddEdge(string(varDefinitionScript.Content), string(script.Content))
+			edge := [2]string{string(varDefinitionScript.Content), string(script.Content)}
+			variablesDAG.edgesVariables[edge] = append(variablesDAG.edgesVariables[edge], varName)
+			err := variablesDAG.AddEdge(edge[0], edge[1])
 			if err != nil {
 				if errors.As(err, &dag.EdgeDuplicateError{}) {
 					continue
 				}
-				return "", fmt.Errorf("failed to add edge for variable %q from %q to %q: %w", varName, varDefinitionScript.Name, script.Name, err)
+				return nil, fmt.Errorf("failed to add edge for variable %q from %q to %q: %w", varName, varDefinitionScript.Name, script.Name, err)
 			}
 		}
 	}
 
-	var (
-		mergedScript string
-		err          error
-	)
-	variablesDAG.DFSWalk(dagVisitorFunc(func(v dag.Vertexer) {
-		id, val := v.Vertex()
-		script, ok := val.(Script)
-		if !ok {
-			err = errors.Join(err, fmt.Errorf("failed to cast vertex %q to Script", id))
-			return
+	for _, script := range scripts {
+		for _, brick := range script.DependsOn {
+			// the bricks which are not part of the plan - filtered out - are ignored
+			for _, dependencyScript := range scriptsByBrick[brick] {
+				if dependencyScript.Content == script.Content {
+					continue
+				}
+				edge := [2]string{string(dependencyScript.Content), string(script.Content)}
+				variablesDAG.dependencyEdges[edge] = true
+				err := variablesDAG.AddEdge(edge[0], edge[1])
+				if err != nil {
+					if errors.As(err, &dag.EdgeDuplicateError{}) {
+						continue
+					}
+					return nil, fmt.Errorf("failed to add edge for the dependency of brick %s on brick %s, from %q to %q: %w",
+						script.Brick, brick, dependencyScript.Name, script.Name, err)
+				}
+			}
 		}
-		mergedScript += fmt.Sprintf("# %s\n", script.Name)
-		mergedScript += strings.TrimSpace(string(script.Content)) + "\n"
-		mergedScript += ".echo\n\n" // we echo an empty line to separate scripts output
-	}))
-	mergedScript = strings.TrimSpace(mergedScript)
+	}
 
-	return mergedScript, err
+	return variablesDAG, nil
 }
@@ -1,6 +1,9 @@
 package masonry
 
 import (
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -255,6 +258,31 @@ host | directory . | file $log_file_path | contents
 
 # Script
 container | from $CTR_SRC
+.echo`,
+		},
+		{
+			name: "multiple scripts with brick dependencies",
+			sourceScripts: []Script{
+				{
+					Name:      "AlpineScript",
+					Content:   "container | from alpine | file /etc/alpine-release | contents",
+					Brick:     "image_alpine",
+					DependsOn: []string{"image_debian", "image_ubuntu"},
+				},
+				{
+					Name:    "DebianScript",
+					Content: "container | from debian | file /etc/debian_version | contents",
+					Brick:   "image_debian",
+				},
+			},
+			expectedScript: `#!/usr/bin/env dagger
+
+# DebianScript
+container | from debian | file /etc/debian_version | contents
+.echo
+
+# AlpineScript
+container | from alpine | file /etc/alpine-release | contents
 .echo`,
 		},
 		{
@@ -285,6 +313,23 @@ container | from $CTR_SRC
 			},
 			expectedError: `would create a loop`,
 		},
+		{
+			name: "circular dependency between variables and bricks",
+			sourceScripts: []Script{
+				{
+					Name:      "Script1",
+					Content:   "a=$(container | from alpine)",
+					Brick:     "image_one",
+					DependsOn: []string{"image_two"},
+				},
+				{
+					Name:    "Script2",
+					Content: "$a | file /etc/alpine-release | contents",
+					Brick:   "image_two",
+				},
+			},
+			expectedError: `failed to add edge for the dependency of brick image_one on brick image_two`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -320,3 +365,132 @@ container | from $CTR_SRC
 		})
 	}
 }
+
+func TestParsePlanFromDirWithFilteredDependency(t *testing.T) {
+	t.Parallel()
+
+	// the "version" brick has been filtered out of the blueprint by a selector
+	blueprint := Blueprint{
+		Bricks: []Brick{
+			{Kind: "GoBinary", Metadata: BrickMetadata{Name: "mason"}},
+			{Kind: "Docs", Metadata: BrickMetadata{Name: "docs", DependsOn: []string{"mason", "version"}}},
+		},
+		workspace: Workspace{mason: NewMason()},
+	}
+
+	planDir := t.TempDir()
+	writeFile(t, filepath.Join(planDir, "golang", "gobinary_mason", "package_mason.dagger"), "mason_bin=$(container | from golang | file /go/bin/mason)")
+	writeFile(t, filepath.Join(planDir, "docs", "docs_docs", "package_docs.dagger"), "directory | with-new-file docs.md hello | export docs")
+
+	plan, err := parsePlanFromDir(planDir, blueprint)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, script := range plan.SourceScripts {
+		if script.Brick != "docs_docs" {
+			continue
+		}
+		if expected := []string{"gobinary_mason"}; !slices.Equal(script.DependsOn, expected) {
+			t.Errorf("expected the script of the docs to depend on %v, got %v", expected, script.DependsOn)
+		}
+	}
+}
+
+func TestBlueprintRenderPlan(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                string
+		bricks              []Brick
+		expectedRenderPaths []string
+	}{
+		{
+			name: "per module",
+			bricks: []Brick{
+				{Kind: "GoBinary", ModuleRef: "golang", Metadata: BrickMetadata{Name: "linux"}},
+				{Kind: "GoBinary", ModuleRef: "golang", Metadata: BrickMetadata{Name: "darwin"}},
+				{Kind: "Docs", ModuleRef: "docs", Metadata: BrickMetadata{Name: "docs"}},
+			},
+			expectedRenderPaths: []string{"docs", "golang"},
+		},
+		{
+			name: "filtered dependency",
+			bricks: []Brick{
+				{Kind: "GoBinary", ModuleRef: "golang", Metadata: BrickMetadata{Name: "linux"}},
+				{Kind: "Docs", ModuleRef: "docs", Metadata: BrickMetadata{Name: "docs", DependsOn: []string{"version"}}},
+			},
+			expectedRenderPaths: []string{"docs", "golang"},
+		},
+		{
+			name: "dependency",
+			bricks: []Brick{
+				{Kind: "GoBinary", ModuleRef: "golang", Metadata: BrickMetadata{Name: "linux"}},
+				{Kind: "GoBinary", ModuleRef: "golang", Metadata: BrickMetadata{Name: "darwin"}},
+				{Kind: "Docs", ModuleRef: "docs", Metadata: BrickMetadata{Name: "docs", DependsOn: []string{"linux"}}},
+			},
+			expectedRenderPaths: []string{"docs/docs_docs", "golang/gobinary_darwin", "golang/gobinary_linux"},
+		},
+		{
+			name: "retries",
+			bricks: []Brick{
+				{Kind: "GoBinary", ModuleRef: "golang", Metadata: BrickMetadata{Name: "linux", Retries: 2}},
+				{Kind: "Docs", ModuleRef: "docs", Metadata: BrickMetadata{Name: "docs"}},
+			},
+			expectedRenderPaths: []string{"docs/docs_docs", "golang/gobinary_linux"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// fake Dagger CLI, recording the script it runs - and rendering an empty plan
+			daggerDir := t.TempDir()
+			daggerBinary := filepath.Join(daggerDir, "dagger")
+			writeFile(t, daggerBinary, `#!/bin/sh
+for script; do :; done
+cp "$script" "$(dirname "$0")/recorded.dagger"
+`)
+			if err := os.Chmod(daggerBinary, 0755); err != nil {
+				t.Fatalf("failed to make the fake Dagger CLI executable: %v", err)
+			}
+
+			mason := NewMason()
+			mason.DaggerBinary = daggerBinary
+			mason.DaggerOutputDisabled = true
+			blueprint := Blueprint{
+				Bricks: tt.bricks,
+				workspace: Workspace{
+					RootPath:     t.TempDir(),
+					RelativePath: ".",
+					mason:        mason,
+					workDirName:  "test",
+				},
+			}
+			if _, err := blueprint.RenderPlan(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			script, err := os.ReadFile(filepath.Join(daggerDir, "recorded.dagger"))
+			if err != nil {
+				t.Fatalf("failed to read the recorded script: %v", err)
+			}
+			var renderPaths []string
+			for _, line := range strings.Split(string(script), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) < 6 || fields[0] != "with-directory" {
+					continue
+				}
+				// with-directory <path> $(<module> | render-plan <blueprint dir>/<path>) |
+				renderPaths = append(renderPaths, fields[1])
+				if !strings.HasSuffix(fields[5], "/blueprint/"+fields[1]+")") {
+					t.Errorf("expected the plan of %s to be rendered from its blueprint directory, got %q", fields[1], line)
+				}
+			}
+			slices.Sort(renderPaths)
+			if !slices.Equal(renderPaths, tt.expectedRenderPaths) {
+				t.Errorf("expected the plan to be rendered for %v, got %v - script:\n%s", tt.expectedRenderPaths, renderPaths, script)
+			}
+		})
+	}
+}
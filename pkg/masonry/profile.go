@@ -0,0 +1,226 @@
+package masonry
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// patchDirective is the field of an overlay - or of one of its maps - controlling how it is applied:
+//   - "delete": on an overlay, removes the matching brick
+//   - "replace": on a map, replaces the matching map instead of merging it
+const patchDirective = "$patch"
+
+func (w Workspace) ProfileDir(profile string) string {
+	return filepath.Join(w.MasonDir(), ProfilesDirName, profile)
+}
+
+// loadOverlays loads the documents of all the files of the profile directory.
+func (w Workspace) loadOverlays(profile string) ([]document, error) {
+	profileDir := w.ProfileDir(profile)
+	if _, err := os.Stat(profileDir); errors.Is(err, fs.ErrNotExist) {
+		w.logger().WithFields("profile", profile).Warn("Profile not found in workspace")
+		return nil, nil
+	}
+
+	var overlays []document
+	err := filepath.WalkDir(profileDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(entry.Name()) {
+		case ".json", ".yaml", ".yml":
+			// valid file types
+		default:
+			return nil
+		}
+
+		file := w.localBlueprintFile(path)
+		fileOverlays, err := loadDocumentsFromFile(file.path, file.name)
+		if err != nil {
+			return err
+		}
+		overlays = append(overlays, fileOverlays...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load profile %s from %s: %w", profile, profileDir, err)
+	}
+	return overlays, nil
+}
+
+// applyProfile applies the overlays of the profile to the documents.
+// Each overlay is matched with a brick by kind and name - the templated name of a brick with a matrix,
+// or the rendered name of one of its combinations, which is then expanded so that it can be patched on its own:
+//   - if there is no such brick, the overlay is added as a new brick
+//   - if the overlay has the "$patch: delete" directive, the brick is removed
+//   - otherwise, the overlay is merged into the brick: maps are merged recursively - unless they have the
+//     "$patch: replace" directive - other values - including lists - are replaced, and null values remove the fields.
+func (w Workspace) applyProfile(documents []document, profile string) ([]document, error) {
+	overlays, err := w.loadOverlays(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	var data TemplateData // only when matching the combinations of a matrix
+	for _, overlay := range overlays {
+		if !overlay.isBrick() {
+			continue // only YAML anchors
+		}
+		key, ok := overlay.brickKey()
+		if !ok {
+			return nil, fmt.Errorf("%s: invalid overlay: missing kind or metadata.name", overlay.Source)
+		}
+		directive, _ := overlay.Fields[patchDirective].(string)
+
+		// the brick - or all the combinations of its matrix, if it has been expanded for a previous overlay
+		var indexes []int
+		for i, document := range documents {
+			documentKey, ok := document.brickKey()
+			if !ok || documentKey != key || (len(indexes) > 0 && document.Source.Matrix == nil) {
+				continue
+			}
+			indexes = append(indexes, i)
+			if document.Source.Matrix == nil {
+				break
+			}
+		}
+		if len(indexes) == 0 {
+			if data == nil {
+				data = w.templateData()
+			}
+			var index int
+			documents, index, err = w.expandMatrixCombination(documents, key, data)
+			if err != nil {
+				return nil, err
+			}
+			if index >= 0 {
+				indexes = append(indexes, index)
+			}
+		}
+
+		switch {
+		case directive == "delete" && len(indexes) == 0:
+			return nil, fmt.Errorf("%s: invalid overlay: no brick to delete", overlay.Source)
+		case directive == "delete":
+			w.logger().WithFields("profile", profile, "brick", key).Debug("Deleting brick")
+			for _, index := range slices.Backward(indexes) {
+				documents = append(documents[:index], documents[index+1:]...)
+			}
+		case directive != "" && directive != "replace":
+			return nil, fmt.Errorf("%s: invalid overlay: unknown directive %s: %q", overlay.Source, patchDirective, directive)
+		case len(indexes) == 0:
+			w.logger().WithFields("profile", profile, "brick", key).Debug("Adding brick")
+			fields, err := mergePatch(nil, overlay.Fields) // to apply the directives and remove the null values
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid overlay: %w", overlay.Source, err)
+			}
+			overlay.Fields, _ = fields.(map[string]any)
+			documents = append(documents, overlay)
+		default:
+			w.logger().WithFields("profile", profile, "brick", key).Debug("Patching brick")
+			for _, index := range indexes {
+				// keep the kind and name of the brick, which are matched case-insensitively
+				overlayFields := maps.Clone(overlay.Fields)
+				overlayFields["kind"] = documents[index].Fields["kind"]
+				overlayMetadata, _ := overlayFields["metadata"].(map[string]any)
+				overlayMetadata = maps.Clone(overlayMetadata)
+				overlayMetadata["name"] = documents[index].Fields["metadata"].(map[string]any)["name"]
+				overlayFields["metadata"] = overlayMetadata
+
+				patched, err := mergePatch(documents[index].Fields, overlayFields)
+				if err != nil {
+					return nil, fmt.Errorf("%s: invalid overlay: %w", overlay.Source, err)
+				}
+				documents[index].Fields, _ = patched.(map[string]any)
+			}
+		}
+	}
+	return documents, nil
+}
+
+// expandMatrixCombination looks for the matrix combination whose rendered kind and name match the key.
+// If found, its matrix is expanded in the documents - if not already - and the index of the combination is returned,
+// otherwise -1.
+func (w Workspace) expandMatrixCombination(documents []document, key string, data TemplateData) ([]document, int, error) {
+	for i, d := range documents {
+		combinations := []document{d}
+		if d.hasMatrix() {
+			var err error
+			combinations, err = w.expandMatrix(d)
+			if err != nil {
+				return nil, -1, err
+			}
+		}
+		for j, combination := range combinations {
+			if combination.Source.Matrix == nil {
+				continue
+			}
+			kind, _ := combination.Fields["kind"].(string)
+			name, _ := combination.brickName()
+			// an invalid name is reported when rendering the documents
+			renderedName, err := renderValue(name, "metadata.name", data.forDocument(combination))
+			if err != nil || strings.ToLower(kind)+"/"+strings.ToLower(fmt.Sprint(renderedName)) != key {
+				continue
+			}
+			return slices.Replace(documents, i, i+1, combinations...), i + j, nil
+		}
+	}
+	return documents, -1, nil
+}
+
+// brickKey returns the lowercase kind and name of the brick defined by the document.
+func (d document) brickKey() (string, bool) {
+	kind, _ := d.Fields["kind"].(string)
+	metadata, _ := d.Fields["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+	if kind == "" || name == "" {
+		return "", false
+	}
+	return strings.ToLower(kind) + "/" + strings.ToLower(name), true
+}
+
+// mergePatch returns the result of the patch applied to the value, without modifying them:
+// documents may share values through YAML anchors.
+func mergePatch(value, patch any) (any, error) {
+	patchMap, ok := patch.(map[string]any)
+	if !ok {
+		return patch, nil
+	}
+
+	merged := make(map[string]any, len(patchMap))
+	switch directive := patchMap[patchDirective]; directive {
+	case nil:
+		if valueMap, ok := value.(map[string]any); ok {
+			maps.Copy(merged, valueMap)
+		}
+	case "replace":
+		// start from an empty map
+	default:
+		return nil, fmt.Errorf("unknown directive %s: %q", patchDirective, directive)
+	}
+
+	for key, patchItem := range patchMap {
+		if key == patchDirective {
+			continue
+		}
+		if patchItem == nil {
+			delete(merged, key)
+			continue
+		}
+		mergedItem, err := mergePatch(merged[key], patchItem)
+		if err != nil {
+			return nil, err
+		}
+		merged[key] = mergedItem
+	}
+	return merged, nil
+}
@@ -0,0 +1,248 @@
+package masonry
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWorkspaceLoadBlueprintWithProfile(t *testing.T) {
+	t.Parallel()
+
+	blueprint := `commonSpec: &commonSpec
+  registry: localhost:5000
+  tags: [dev]
+  push: false
+---
+kind: OCIImage
+moduleRef: oci
+metadata:
+  name: api
+spec:
+  <<: *commonSpec
+  platform: linux/amd64
+---
+kind: OCIImage
+moduleRef: oci
+metadata:
+  name: worker
+spec:
+  <<: *commonSpec
+  build:
+    context: worker
+    target: prod
+---
+kind: RunBinary
+moduleRef: run
+metadata:
+  name: local-server
+`
+
+	tests := []struct {
+		name          string
+		profile       string
+		overlays      map[string]string
+		expectedSpecs []string
+		expectedError string
+	}{
+		{
+			name:    "no profile",
+			profile: "",
+			overlays: map[string]string{
+				"ci/images.yaml": "kind: OCIImage\nmetadata:\n  name: api\n$patch: delete\n",
+			},
+			expectedSpecs: []string{
+				"OCIImage/api: map[platform:linux/amd64 push:false registry:localhost:5000 tags:[dev]]",
+				"OCIImage/worker: map[build:map[context:worker target:prod] push:false registry:localhost:5000 tags:[dev]]",
+				"RunBinary/local-server: <nil>",
+			},
+		},
+		{
+			name:    "missing profile",
+			profile: "release",
+			overlays: map[string]string{
+				"ci/images.yaml": "kind: OCIImage\nmetadata:\n  name: api\n$patch: delete\n",
+			},
+			expectedSpecs: []string{
+				"OCIImage/api: map[platform:linux/amd64 push:false registry:localhost:5000 tags:[dev]]",
+				"OCIImage/worker: map[build:map[context:worker target:prod] push:false registry:localhost:5000 tags:[dev]]",
+				"RunBinary/local-server: <nil>",
+			},
+		},
+		{
+			name:    "patch, add and delete bricks",
+			profile: "ci",
+			overlays: map[string]string{
+				"ci/images.yaml": `kind: OCIImage
+metadata:
+  name: api
+  templated: true
+spec:
+  registry: ghcr.io/example
+  tags: [latest, "{{ .vars.version }}"]
+  push: true
+  platform: null
+---
+kind: ociimage
+metadata:
+  name: WORKER
+spec:
+  build:
+    target: debug
+`,
+				"ci/nested/bricks.yaml": `kind: RunBinary
+metadata:
+  name: local-server
+$patch: delete
+---
+kind: GoTest
+moduleRef: golang
+metadata:
+  name: integration
+spec:
+  tags: [integration]
+  race: null
+`,
+			},
+			expectedSpecs: []string{
+				"OCIImage/api: map[push:true registry:ghcr.io/example tags:[latest 1.2.3]]",
+				"OCIImage/worker: map[build:map[context:worker target:debug] push:false registry:localhost:5000 tags:[dev]]",
+				"GoTest/integration: map[tags:[integration]]",
+			},
+		},
+		{
+			name:    "replace",
+			profile: "ci",
+			overlays: map[string]string{
+				"ci/images.yaml": `kind: OCIImage
+metadata:
+  name: worker
+spec:
+  build:
+    $patch: replace
+    dockerfile: Dockerfile.ci
+`,
+			},
+			expectedSpecs: []string{
+				"OCIImage/api: map[platform:linux/amd64 push:false registry:localhost:5000 tags:[dev]]",
+				"OCIImage/worker: map[build:map[dockerfile:Dockerfile.ci] push:false registry:localhost:5000 tags:[dev]]",
+				"RunBinary/local-server: <nil>",
+			},
+		},
+		{
+			name:    "delete unknown brick",
+			profile: "ci",
+			overlays: map[string]string{
+				"ci/images.yaml": "kind: OCIImage\nmetadata:\n  name: web\n$patch: delete\n",
+			},
+			expectedError: "profiles/ci/images.yaml (document 0): invalid overlay: no brick to delete",
+		},
+		{
+			name:    "overlay without name",
+			profile: "ci",
+			overlays: map[string]string{
+				"ci/images.yaml": "kind: OCIImage\nspec:\n  push: true\n",
+			},
+			expectedError: "profiles/ci/images.yaml (document 0): invalid overlay: missing kind or metadata.name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rootPath := t.TempDir()
+			writeFile(t, filepath.Join(rootPath, MasonDirName, "bricks.yaml"), blueprint)
+			for name, content := range tt.overlays {
+				writeFile(t, filepath.Join(rootPath, MasonDirName, ProfilesDirName, filepath.FromSlash(name)), content)
+			}
+
+			mason := NewMason()
+			mason.Profile = tt.profile
+			workspace := Workspace{
+				RootPath:     rootPath,
+				RelativePath: ".",
+				Config:       WorkspaceConfig{Variables: map[string]string{"version": "1.2.3"}},
+				mason:        mason,
+			}
+
+			blueprint, err := workspace.LoadBlueprint()
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error to contain %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var specs []string
+			for _, brick := range blueprint.Bricks {
+				specs = append(specs, fmt.Sprintf("%s/%s: %v", brick.Kind, brick.Metadata.Name, brick.Spec))
+			}
+			if !slices.Equal(specs, tt.expectedSpecs) {
+				t.Errorf("expected specs\n%s\ngot\n%s", strings.Join(tt.expectedSpecs, "\n"), strings.Join(specs, "\n"))
+			}
+		})
+	}
+}
+
+func TestWorkspaceLoadBlueprintWithProfileOnMatrix(t *testing.T) {
+	t.Parallel()
+
+	rootPath := t.TempDir()
+	writeFile(t, filepath.Join(rootPath, MasonDirName, "bricks.yaml"), `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: mason-{{ .matrix.os }}
+  matrix:
+    os: [darwin, linux, windows]
+spec:
+  os: "{{ .matrix.os }}"
+  cgo: true
+`)
+	writeFile(t, filepath.Join(rootPath, MasonDirName, ProfilesDirName, "ci", "bricks.yaml"), `kind: GoBinary
+metadata:
+  name: MASON-LINUX
+spec:
+  static: true
+---
+kind: GoBinary
+metadata:
+  name: mason-windows
+$patch: delete
+---
+kind: GoBinary
+metadata:
+  name: mason-{{ .matrix.os }}
+spec:
+  cgo: false
+`)
+
+	mason := NewMason()
+	mason.Profile = "ci"
+	workspace := Workspace{
+		RootPath:     rootPath,
+		RelativePath: ".",
+		mason:        mason,
+	}
+
+	blueprint, err := workspace.LoadBlueprint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var specs []string
+	for _, brick := range blueprint.Bricks {
+		specs = append(specs, fmt.Sprintf("%s/%s: %v", brick.Kind, brick.Metadata.Name, brick.Spec))
+	}
+	expectedSpecs := []string{
+		"GoBinary/mason-darwin: map[cgo:false os:darwin]",
+		"GoBinary/mason-linux: map[cgo:false os:linux static:true]",
+	}
+	if !slices.Equal(specs, expectedSpecs) {
+		t.Errorf("expected specs\n%s\ngot\n%s", strings.Join(expectedSpecs, "\n"), strings.Join(specs, "\n"))
+	}
+}
@@ -0,0 +1,393 @@
+package masonry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rs/xid"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// missingSchemaFunctionRegexp matches the errors of Dagger when a module doesn't have a "schema" function.
+var missingSchemaFunctionRegexp = regexp.MustCompile(`(?i)(function|command|field) "schema" (not found|does not exist)|` +
+	`unknown (function|command) "schema"|cannot query field "schema"`)
+
+// SpecSchema is a JSON Schema published by a module, to validate the spec of the bricks of a kind.
+type SpecSchema struct {
+	Kind       string
+	ModuleRef  ModuleRef
+	ModuleRefs []string // the references of the module in the blueprint files - resolved, and its aliases
+	Document   any      // the raw JSON Schema
+
+	schema *jsonschema.Schema
+}
+
+func (s SpecSchema) Validate(spec any) error {
+	// round-trip through JSON, to only give JSON types to the validator
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("failed to encode spec: %w", err)
+	}
+	instance, err := jsonschema.UnmarshalJSON(strings.NewReader(string(data)))
+	if err != nil {
+		return fmt.Errorf("failed to decode spec: %w", err)
+	}
+	err = s.schema.Validate(instance)
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		// the first line is just "validation failed with <schema URL>", the details are below
+		if _, details, ok := strings.Cut(validationErr.Error(), "\n"); ok {
+			return errors.New(details)
+		}
+	}
+	return err
+}
+
+// SpecSchemas are the spec schemas, indexed by lowercase kind and module reference - see specSchemaKey:
+// 2 modules can define the same kind.
+type SpecSchemas map[string]SpecSchema
+
+func specSchemaKey(kind string, moduleRef ModuleRef) string {
+	return strings.ToLower(kind) + " " + string(moduleRef)
+}
+
+// ForBrick returns the schema of the spec of the brick, for its kind and module.
+func (s SpecSchemas) ForBrick(brick Brick) (SpecSchema, bool) {
+	schema, ok := s[specSchemaKey(brick.Kind, brick.ModuleRef)]
+	return schema, ok
+}
+
+// LoadSpecSchemas calls the optional "schema" function of each module used by the blueprint.
+// This function must return a directory with 1 JSON Schema file per kind, named "<kind>.json".
+// Modules without such a function are ignored, but any other failure of Dagger is an error.
+func (b Blueprint) LoadSpecSchemas() (SpecSchemas, error) {
+	schemasName := xid.New().String()
+	schemas := make(SpecSchemas)
+	for moduleRef, blueprint := range b.splitByModuleRef() {
+		moduleSchemasDir := filepath.Join(b.workspace.WorkDir(), schemasName, SchemaDirPrefix, moduleRef.SanitizedName())
+		err := os.MkdirAll(moduleSchemasDir, os.ModePerm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create directory %s: %w", moduleSchemasDir, err)
+		}
+
+		pinnedModuleRef, err := b.workspace.pinnedModuleRef(moduleRef)
+		if err != nil {
+			return nil, err
+		}
+		daggerScript := "#!/usr/bin/env dagger\n\n"
+		daggerScript += fmt.Sprintf("%s | schema | export %s\n", pinnedModuleRef, moduleSchemasDir)
+		daggerScriptFilePath := filepath.Join(moduleSchemasDir, "schema.dagger")
+		err = os.WriteFile(daggerScriptFilePath, []byte(daggerScript), 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to write file %q: %w", daggerScriptFilePath, err)
+		}
+
+		b.logger().WithFields("module", moduleRef).Info("Loading module schemas with Dagger")
+		logFilePath := filepath.Join(moduleSchemasDir, "dagger_schema.log")
+		_, err = b.workspace.execDaggerScript(daggerScriptFilePath, logFilePath)
+		if err != nil {
+			if logs, _ := os.ReadFile(logFilePath); missingSchemaFunctionRegexp.Match(logs) {
+				b.logger().WithFields("module", moduleRef).Info("Module does not provide schemas")
+				continue
+			}
+			return nil, fmt.Errorf("failed to load the schemas of module %s - see %s: %w", moduleRef, logFilePath, err)
+		}
+
+		moduleRefs := []string{string(moduleRef)}
+		for alias, aliasedModuleRef := range b.workspace.Config.Modules {
+			if aliasedModuleRef == moduleRef {
+				moduleRefs = append(moduleRefs, alias)
+			}
+		}
+		slices.Sort(moduleRefs)
+		for _, brick := range blueprint.Bricks {
+			if _, ok := schemas.ForBrick(brick); ok {
+				continue
+			}
+			schemaFileName := strings.ToLower(brick.Kind) + ".json"
+			schemaFilePath := filepath.Join(moduleSchemasDir, schemaFileName)
+			schemaLocation := fmt.Sprintf("mason:///%s/%s", moduleRef.SanitizedName(), schemaFileName)
+			schema, err := loadSpecSchema(schemaFilePath, schemaLocation)
+			if os.IsNotExist(err) {
+				b.logger().WithFields("module", moduleRef, "kind", brick.Kind).
+					Debug("Module does not provide a schema for kind")
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to load schema for kind %s from module %s: %w", brick.Kind, moduleRef, err)
+			}
+			schema.Kind = brick.Kind
+			schema.ModuleRef = moduleRef
+			schema.ModuleRefs = moduleRefs
+			schemas[specSchemaKey(brick.Kind, moduleRef)] = *schema
+		}
+	}
+	return schemas, nil
+}
+
+func loadSpecSchema(filePath, location string) (*SpecSchema, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck // we're just reading the file...
+
+	document, err := jsonschema.UnmarshalJSON(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", filePath, err)
+	}
+	return compileSpecSchema(location, document)
+}
+
+func compileSpecSchema(location string, document any) (*SpecSchema, error) {
+	compiler := jsonschema.NewCompiler()
+	err := compiler.AddResource(location, document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add schema %s: %w", location, err)
+	}
+	schema, err := compiler.Compile(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile schema %s: %w", location, err)
+	}
+	return &SpecSchema{
+		Document: document,
+		schema:   schema,
+	}, nil
+}
+
+// BrickValidation is the result of the validation of a brick's spec.
+type BrickValidation struct {
+	Brick     Brick
+	HasSchema bool
+	Err       error
+}
+
+func (b Blueprint) ValidateSpecs(schemas SpecSchemas) []BrickValidation {
+	validations := make([]BrickValidation, 0, len(b.Bricks))
+	for _, brick := range b.Bricks {
+		validation := BrickValidation{Brick: brick}
+		if schema, ok := schemas.ForBrick(brick); ok {
+			validation.HasSchema = true
+			validation.Err = schema.Validate(brick.Spec)
+		}
+		validations = append(validations, validation)
+	}
+	return validations
+}
+
+// BlueprintSchema returns the JSON Schema of the documents of the blueprint files - for the editors -
+// with the given spec schemas applied to the spec of the bricks of their kind.
+// The documents are the bricks, the documents including other files,
+// and the documents without any of the brick fields - only used to hold YAML anchors.
+func BlueprintSchema(specSchemas SpecSchemas) map[string]any {
+	stringSchema := map[string]any{"type": "string"}
+	scalarSchema := map[string]any{"type": []any{"string", "number", "boolean"}}
+
+	metadataSchema := map[string]any{
+		"type":     "object",
+		"required": []any{"name"},
+		"properties": map[string]any{
+			"name": map[string]any{
+				"type":        "string",
+				"description": "Name of the brick - unique for its kind in the workspace",
+			},
+			"labels": map[string]any{
+				"type":                 "object",
+				"description":          "Labels of the brick, used by the label selectors",
+				"additionalProperties": stringSchema,
+			},
+			"extraPhases": map[string]any{
+				"type":        "array",
+				"description": "Phases of the brick, in addition to the ones of its module",
+				"items":       stringSchema,
+			},
+			"postRun": map[string]any{
+				"type":        "string",
+				"description": "Run the scripts of the brick after the other ones - even if they failed",
+				"enum":        []any{string(PostRunAlways), string(PostRunOnSuccess), string(PostRunOnFailure)},
+			},
+			"inputs": map[string]any{
+				"type":        "array",
+				"description": "Glob patterns of the files of the workspace used by the brick, for --since",
+				"items":       stringSchema,
+			},
+			"dependsOn": map[string]any{
+				"type":        "array",
+				"description": "Names of the bricks whose scripts must run before the scripts of the brick",
+				"items":       stringSchema,
+			},
+			"when": map[string]any{
+				"type":        []any{"string", "boolean"},
+				"description": "Go template condition: the brick is excluded if it evaluates to false",
+			},
+			"matrix": map[string]any{
+				"type":        "object",
+				"description": "Lists of values: the brick is expanded into 1 brick per combination",
+				"properties": map[string]any{
+					matrixExcludeKey: map[string]any{
+						"type":        "array",
+						"description": "Combinations - or partial combinations - to exclude",
+						"items": map[string]any{
+							"type":                 "object",
+							"additionalProperties": scalarSchema,
+						},
+					},
+				},
+				"additionalProperties": map[string]any{
+					"type":     "array",
+					"minItems": 1,
+					"items":    scalarSchema,
+				},
+			},
+			"extends": map[string]any{
+				"type":        "string",
+				"description": "Name of the brick to inherit the kind, moduleRef, labels and spec from",
+			},
+			"abstract": map[string]any{
+				"type":        "boolean",
+				"description": "Only use the brick to be extended by other bricks",
+			},
+			"timeout": map[string]any{
+				"type":        "string",
+				"description": "Maximum duration of each attempt to run the scripts of the brick - such as 90s or 1h30m",
+				"pattern":     `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`,
+			},
+			"retries": map[string]any{
+				"type":        "integer",
+				"description": "Number of times the scripts of the brick are run again if they fail",
+				"minimum":     0,
+			},
+			"templated": map[string]any{
+				"type":        "boolean",
+				"description": "Render the string values of the brick as Go templates - always true for the bricks with a matrix",
+			},
+		},
+		"additionalProperties": false,
+	}
+
+	brickSchema := map[string]any{
+		"required": []any{"metadata"},
+		"properties": map[string]any{
+			"kind": map[string]any{
+				"type":        "string",
+				"description": "Kind of the brick, defined by its module",
+			},
+			"moduleRef": map[string]any{
+				"type":        "string",
+				"description": "Reference of the Dagger module processing the brick - or an alias defined in config.yaml",
+			},
+			"metadata": metadataSchema,
+			"spec": map[string]any{
+				"description": "Specification of the brick, defined by its module",
+			},
+		},
+		"additionalProperties": false,
+		"allOf": []any{
+			map[string]any{
+				// the kind and moduleRef of an extending brick can be inherited
+				"if": map[string]any{
+					"properties": map[string]any{
+						"metadata": map[string]any{"required": []any{"extends"}},
+					},
+				},
+				"else": map[string]any{"required": []any{"kind", "moduleRef"}},
+			},
+		},
+	}
+	modulesByKind := make(map[string]int)
+	for _, specSchema := range specSchemas {
+		modulesByKind[strings.ToLower(specSchema.Kind)]++
+	}
+	for _, key := range slices.Sorted(maps.Keys(specSchemas)) {
+		specSchema := specSchemas[key]
+		kind := strings.ToLower(specSchema.Kind)
+		document := specSchema.Document
+		if fields, ok := document.(map[string]any); ok {
+			if _, ok := fields["$id"]; !ok {
+				// so that the references of the schema are resolved against it
+				fields = maps.Clone(fields)
+				fields["$id"] = fmt.Sprintf("mason:///%s/%s.json", specSchema.ModuleRef.SanitizedName(), kind)
+				document = fields
+			}
+		}
+		condition := map[string]any{
+			"required":   []any{"kind"},
+			"properties": map[string]any{"kind": map[string]any{"const": specSchema.Kind}},
+		}
+		if modulesByKind[kind] > 1 {
+			// the kind is defined by several modules: the module of the brick selects the schema
+			moduleRefs := make([]any, 0, len(specSchema.ModuleRefs))
+			for _, moduleRef := range specSchema.ModuleRefs {
+				moduleRefs = append(moduleRefs, moduleRef)
+			}
+			condition = map[string]any{
+				"required": []any{"kind", "moduleRef"},
+				"properties": map[string]any{
+					"kind":      map[string]any{"const": specSchema.Kind},
+					"moduleRef": map[string]any{"enum": moduleRefs},
+				},
+			}
+		}
+		brickSchema["allOf"] = append(brickSchema["allOf"].([]any), map[string]any{
+			"if": condition,
+			"then": map[string]any{
+				"properties": map[string]any{"spec": document},
+			},
+		})
+	}
+
+	includeSchema := map[string]any{
+		"required": []any{includeField},
+		"properties": map[string]any{
+			includeField: map[string]any{
+				"type":        "array",
+				"description": "Blueprint files to include",
+				"items": map[string]any{
+					"anyOf": []any{
+						map[string]any{
+							"type":        "string",
+							"description": "Path of a local file, relative to the including file",
+						},
+						map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"path":   stringSchema,
+								"url":    stringSchema,
+								"digest": map[string]any{"type": "string", "pattern": sha256DigestRegexp.String()},
+								"git":    stringSchema,
+								"ref":    map[string]any{"type": "string", "pattern": gitCommitRegexp.String()},
+							},
+							"additionalProperties": false,
+						},
+					},
+				},
+			},
+		},
+		"additionalProperties": false,
+	}
+
+	var brickFields []any
+	for _, field := range []string{"kind", "moduleRef", "metadata", "spec"} {
+		brickFields = append(brickFields, map[string]any{"required": []any{field}})
+	}
+	return map[string]any{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"$id":         "mason:///blueprint.json",
+		"title":       "Mason blueprint",
+		"description": "A document of a blueprint file, from a .mason directory",
+		"type":        "object",
+		"if":          map[string]any{"anyOf": brickFields},
+		"then":        brickSchema,
+		"else": map[string]any{
+			"if":   map[string]any{"required": []any{includeField}},
+			"then": includeSchema,
+		},
+	}
+}
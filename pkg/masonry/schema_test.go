@@ -0,0 +1,425 @@
+package masonry
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+func TestBlueprintValidateSpecs(t *testing.T) {
+	t.Parallel()
+
+	document, err := jsonschema.UnmarshalJSON(strings.NewReader(`{
+		"type": "object",
+		"properties": {
+			"os": {"enum": ["linux", "darwin"]},
+			"arch": {"type": "string"},
+			"buildArgs": {"type": "array", "items": {"type": "string"}}
+		},
+		"required": ["os", "arch"],
+		"additionalProperties": false
+	}`))
+	if err != nil {
+		t.Fatalf("failed to decode schema: %v", err)
+	}
+	schema, err := compileSpecSchema("mason:///golang/gobinary.json", document)
+	if err != nil {
+		t.Fatalf("failed to compile schema: %v", err)
+	}
+	schemas := SpecSchemas{specSchemaKey("GoBinary", "golang"): *schema}
+
+	tests := []struct {
+		name              string
+		brick             Brick
+		expectedHasSchema bool
+		expectedError     string
+	}{
+		{
+			name: "valid spec",
+			brick: Brick{
+				Kind:      "GoBinary",
+				ModuleRef: "golang",
+				Spec:      map[string]any{"os": "linux", "arch": "amd64", "buildArgs": []any{"-ldflags"}},
+			},
+			expectedHasSchema: true,
+		},
+		{
+			name: "invalid enum value",
+			brick: Brick{
+				Kind:      "GoBinary",
+				ModuleRef: "golang",
+				Spec:      map[string]any{"os": "windows", "arch": "amd64"},
+			},
+			expectedHasSchema: true,
+			expectedError:     "at '/os': value must be one of 'linux', 'darwin'",
+		},
+		{
+			name: "typo in a key",
+			brick: Brick{
+				Kind:      "GoBinary",
+				ModuleRef: "golang",
+				Spec:      map[string]any{"os": "linux", "arch": "amd64", "buildArg": []any{"-ldflags"}},
+			},
+			expectedHasSchema: true,
+			expectedError:     "additional properties 'buildArg' not allowed",
+		},
+		{
+			name: "yaml integer",
+			brick: Brick{
+				Kind:      "GoBinary",
+				ModuleRef: "golang",
+				Spec:      map[string]any{"os": "linux", "arch": uint64(64)},
+			},
+			expectedHasSchema: true,
+			expectedError:     "at '/arch': got number, want string",
+		},
+		{
+			name: "kind of another module",
+			brick: Brick{
+				Kind:      "GoBinary",
+				ModuleRef: "tinygo",
+				Spec:      map[string]any{"anything": true},
+			},
+		},
+		{
+			name: "kind without schema",
+			brick: Brick{
+				Kind: "GoTest",
+				Spec: map[string]any{"anything": true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			validations := Blueprint{Bricks: []Brick{tt.brick}}.ValidateSpecs(schemas)
+			if len(validations) != 1 {
+				t.Fatalf("expected 1 validation, got %d", len(validations))
+			}
+			validation := validations[0]
+			if validation.HasSchema != tt.expectedHasSchema {
+				t.Errorf("expected has schema %v, got %v", tt.expectedHasSchema, validation.HasSchema)
+			}
+			if tt.expectedError == "" {
+				if validation.Err != nil {
+					t.Errorf("unexpected error: %v", validation.Err)
+				}
+				return
+			}
+			if validation.Err == nil || !strings.Contains(validation.Err.Error(), tt.expectedError) {
+				t.Errorf("expected error to contain %q, got %v", tt.expectedError, validation.Err)
+			}
+		})
+	}
+}
+
+func TestBlueprintSchema(t *testing.T) {
+	t.Parallel()
+
+	document, err := jsonschema.UnmarshalJSON(strings.NewReader(`{
+		"type": "object",
+		"properties": {
+			"os": {"$ref": "#/definitions/os"}
+		},
+		"definitions": {
+			"os": {"enum": ["linux", "darwin"]}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("failed to decode schema: %v", err)
+	}
+	specSchema, err := compileSpecSchema("mason:///golang/gobinary.json", document)
+	if err != nil {
+		t.Fatalf("failed to compile schema: %v", err)
+	}
+	specSchema.Kind = "GoBinary"
+	specSchema.ModuleRef = "golang"
+
+	blueprintSchema := BlueprintSchema(SpecSchemas{specSchemaKey("GoBinary", "golang"): *specSchema})
+	data, err := json.Marshal(blueprintSchema)
+	if err != nil {
+		t.Fatalf("failed to encode schema: %v", err)
+	}
+	document, err = jsonschema.UnmarshalJSON(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("failed to decode schema: %v", err)
+	}
+	schema, err := compileSpecSchema("mason:///blueprint.json", document)
+	if err != nil {
+		t.Fatalf("failed to compile schema: %v", err)
+	}
+
+	t.Run("metadata fields", func(t *testing.T) {
+		t.Parallel()
+		properties := blueprintSchema["then"].(map[string]any)["properties"].(map[string]any)
+		metadataProperties := properties["metadata"].(map[string]any)["properties"].(map[string]any)
+		metadataType := reflect.TypeOf(BrickMetadata{})
+		for i := range metadataType.NumField() {
+			name, _, _ := strings.Cut(metadataType.Field(i).Tag.Get("json"), ",")
+			if _, ok := metadataProperties[name]; !ok {
+				t.Errorf("expected the metadata.%s field in the schema", name)
+			}
+		}
+	})
+
+	tests := []struct {
+		name          string
+		document      string
+		expectedError string
+	}{
+		{
+			name: "brick",
+			document: `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: mason-{{ .matrix.os }}
+  labels:
+    os: "{{ .matrix.os }}"
+  when: true
+  timeout: 1m30s
+  retries: 2
+  matrix:
+    os: [linux, darwin]
+    go: [1.24, 1.25]
+    exclude:
+      - os: darwin
+spec:
+  os: linux
+`,
+		},
+		{
+			name: "extending brick",
+			document: `metadata:
+  name: mason-darwin
+  extends: go-binary
+spec:
+  os: darwin
+`,
+		},
+		{
+			name: "include",
+			document: `include:
+  - common.yaml
+  - url: https://example.com/bricks.yaml
+    digest: sha256:` + strings.Repeat("0", 64) + `
+`,
+		},
+		{
+			name: "anchors",
+			document: `defaults: &defaults
+  os: linux
+`,
+		},
+		{
+			name: "brick without kind",
+			document: `moduleRef: golang
+metadata:
+  name: mason
+`,
+			expectedError: "missing property 'kind'",
+		},
+		{
+			name: "invalid postRun",
+			document: `kind: GoTest
+moduleRef: golang
+metadata:
+  name: tests
+  postRun: never
+`,
+			expectedError: "at '/metadata/postRun': value must be one of",
+		},
+		{
+			name: "invalid timeout",
+			document: `kind: GoTest
+moduleRef: golang
+metadata:
+  name: tests
+  timeout: 10
+`,
+			expectedError: "at '/metadata/timeout': got number, want string",
+		},
+		{
+			name: "unknown metadata field",
+			document: `kind: GoTest
+moduleRef: golang
+metadata:
+  name: tests
+  label: {}
+`,
+			expectedError: "at '/metadata': additional properties 'label' not allowed",
+		},
+		{
+			name: "invalid spec",
+			document: `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: mason
+spec:
+  os: windows
+`,
+			expectedError: "at '/spec/os': value must be one of 'linux', 'darwin'",
+		},
+		{
+			name: "include with other fields",
+			document: `include: [common.yaml]
+defaults: {}
+`,
+			expectedError: "additional properties 'defaults' not allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var document any
+			if err := yaml.Unmarshal([]byte(tt.document), &document); err != nil {
+				t.Fatalf("failed to decode document: %v", err)
+			}
+			err := schema.Validate(document)
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("expected error to contain %q, got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestBlueprintLoadSpecSchemas(t *testing.T) {
+	t.Parallel()
+
+	// fake Dagger CLI, exporting the schemas of the modules - or failing
+	daggerBinary := filepath.Join(t.TempDir(), "dagger")
+	writeFile(t, daggerBinary, `#!/bin/sh
+for script; do :; done
+content=$(cat "$script")
+dir=${content##* export }
+case "$content" in
+  *"./modules/docker | schema"*) mkdir -p "$dir"; echo '{"required": ["dockerfile"]}' > "$dir/image.json" ;;
+  *"./modules/nix | schema"*) mkdir -p "$dir"; echo '{"required": ["flake"]}' > "$dir/image.json" ;;
+  *"./modules/legacy | schema"*) echo 'Error: function "schema" not found' >&2; exit 1 ;;
+  *) echo "failed to resolve module: connection refused" >&2; exit 1 ;;
+esac
+`)
+	if err := os.Chmod(daggerBinary, 0755); err != nil {
+		t.Fatalf("failed to make the fake Dagger CLI executable: %v", err)
+	}
+
+	const bricks = `kind: Image
+moduleRef: docker
+metadata:
+  name: api
+spec:
+  dockerfile: Dockerfile
+---
+kind: Image
+moduleRef: ./modules/nix
+metadata:
+  name: worker
+spec:
+  dockerfile: Dockerfile
+---
+kind: Lint
+moduleRef: ./modules/legacy
+metadata:
+  name: lint
+`
+
+	tests := []struct {
+		name                string
+		extraBrick          string
+		expectedValidations []string
+		expectedError       string
+	}{
+		{
+			name: "modules with and without schemas",
+			expectedValidations: []string{
+				"Image/api: valid",
+				"Image/worker: - at '': missing property 'flake'",
+				"Lint/lint: no schema",
+			},
+		},
+		{
+			name: "failing module",
+			extraBrick: `---
+kind: Test
+moduleRef: ./modules/broken
+metadata:
+  name: unit
+`,
+			expectedError: "failed to load the schemas of module ./modules/broken",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rootPath := t.TempDir()
+			writeFile(t, filepath.Join(rootPath, MasonDirName, WorkspaceConfigFileName), "modules:\n  docker: ./modules/docker\n")
+			writeFile(t, filepath.Join(rootPath, MasonDirName, "bricks.yaml"), bricks+tt.extraBrick)
+
+			mason := NewMason()
+			mason.DaggerBinary = daggerBinary
+			mason.DaggerOutputDisabled = true
+			workspace := Workspace{
+				RootPath:     rootPath,
+				RelativePath: ".",
+				mason:        mason,
+				workDirName:  "test",
+			}
+			config, err := workspace.LoadConfig()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			workspace.Config = *config
+			blueprint, err := workspace.LoadBlueprint()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			schemas, err := blueprint.LoadSpecSchemas()
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error to contain %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var validations []string
+			for _, validation := range blueprint.ValidateSpecs(schemas) {
+				result := "valid"
+				switch {
+				case !validation.HasSchema:
+					result = "no schema"
+				case validation.Err != nil:
+					result = validation.Err.Error()
+				}
+				validations = append(validations, validation.Brick.Kind+"/"+validation.Brick.Metadata.Name+": "+result)
+			}
+			if !slices.Equal(validations, tt.expectedValidations) {
+				t.Errorf("expected validations %q, got %q", tt.expectedValidations, validations)
+			}
+			if schema := schemas[specSchemaKey("Image", "./modules/docker")]; !slices.Equal(schema.ModuleRefs, []string{"./modules/docker", "docker"}) {
+				t.Errorf("expected the references of the module with its alias, got %q", schema.ModuleRefs)
+			}
+		})
+	}
+}
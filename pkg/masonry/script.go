@@ -15,6 +15,10 @@ type Script struct {
 	PostRun    PostRun
 	Name       string
 	Content    dagger.Script
+	FilePath   string // path of the file the script has been parsed from
+
+	Brick     string   // ID of the brick which produced the script - if known
+	DependsOn []string // IDs of the bricks whose scripts must run before this script
 }
 
 func ScriptFromFile(filePath string) (*Script, error) {
@@ -57,6 +61,7 @@ func ScriptFromFile(filePath string) (*Script, error) {
 		PostRun:    postRun,
 		Name:       name,
 		Content:    dagger.Script(content),
+		FilePath:   filePath,
 	}, nil
 }
 
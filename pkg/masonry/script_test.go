@@ -96,6 +96,9 @@ func TestScriptFromFile(t *testing.T) {
 			if script.Content != test.expected.Content {
 				t.Errorf("expected content %q, got %q", test.expected.Content, script.Content)
 			}
+			if script.FilePath != test.filePath {
+				t.Errorf("expected file path %q, got %q", test.filePath, script.FilePath)
+			}
 		})
 	}
 }
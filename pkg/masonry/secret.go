@@ -0,0 +1,150 @@
+package masonry
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	// secretEnvPrefix is the prefix of the environment variables used to pass to Dagger the secrets resolved by a command.
+	secretEnvPrefix = "MASON_SECRET_"
+	// minSecretLength is the minimum length of the values of the secrets:
+	// they must be redacted, and redacting shorter values - such as "1" - would mangle the outputs.
+	minSecretLength = 4
+)
+
+// SecretSource is where the value of a secret comes from. Exactly one of its fields must be set.
+type SecretSource struct {
+	Env     string `json:"env,omitempty"`     // name of an environment variable
+	File    string `json:"file,omitempty"`    // path of a file, relative to the workspace directory
+	Command string `json:"command,omitempty"` // shell command printing the secret, run from the workspace directory
+}
+
+func (s SecretSource) validate() error {
+	sources := 0
+	for _, source := range []string{s.Env, s.File, s.Command} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return errors.New("exactly one of env, file or command must be set")
+	}
+	return nil
+}
+
+// secretReferences returns the Dagger secret references of the secrets of the workspace, by name.
+// The secrets resolved by a command are passed to Dagger through an environment variable.
+func (w Workspace) secretReferences() map[string]string {
+	references := make(map[string]string)
+	for name, source := range w.Config.Secrets {
+		switch {
+		case source.Env != "":
+			references[name] = "env://" + source.Env
+		case source.File != "":
+			references[name] = "file://" + w.secretFilePath(source.File)
+		case source.Command != "":
+			references[name] = "env://" + secretEnvName(name)
+		}
+	}
+	return references
+}
+
+func (w Workspace) secretFilePath(filePath string) string {
+	if filepath.IsAbs(filePath) {
+		return filePath
+	}
+	absPath, err := filepath.Abs(filepath.Join(w.Dir(), filePath))
+	if err != nil {
+		return filepath.Join(w.Dir(), filePath)
+	}
+	return absPath
+}
+
+// secretEnvName returns the name of the environment variable of a secret resolved by a command.
+func secretEnvName(name string) string {
+	return secretEnvPrefix + strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || (!unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+			return '_'
+		}
+		return unicode.ToUpper(r)
+	}, name)
+}
+
+// resolvedSecrets are the values of the secrets of a workspace - to redact them from the outputs -
+// and the environment of Dagger, with the secrets resolved by a command.
+type resolvedSecrets struct {
+	values    []string
+	daggerEnv []string
+}
+
+// resolveSecrets reads the values of the secrets of the workspace - once - and adds them to the redact store.
+// It is only called to apply the plans: rendering them only needs the secret references.
+// The secrets from a missing environment variable or file have no value: Dagger fails if they are used.
+// A value shorter than minSecretLength is an error: it can't be redacted.
+func (w Workspace) resolveSecrets() (*resolvedSecrets, error) {
+	if w.mason.secrets != nil {
+		if resolved, ok := w.mason.secrets.Load(w.Dir()); ok {
+			return resolved.(*resolvedSecrets), nil
+		}
+	}
+
+	resolved := &resolvedSecrets{
+		daggerEnv: slices.Clone(w.mason.DaggerEnv),
+	}
+	for _, name := range slices.Sorted(maps.Keys(w.Config.Secrets)) {
+		source := w.Config.Secrets[name]
+		var value string
+		switch {
+		case source.Env != "":
+			value = os.Getenv(source.Env)
+		case source.File != "":
+			content, err := os.ReadFile(w.secretFilePath(source.File))
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("failed to read secret %q: %w", name, err)
+			}
+			value = string(content)
+		case source.Command != "":
+			w.logger().WithFields("secret", name).Debug("Resolving secret with its command")
+			var stdout, stderr bytes.Buffer
+			cmd := exec.Command("sh", "-c", source.Command)
+			cmd.Dir = w.Dir()
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			if err := cmd.Run(); err != nil {
+				return nil, fmt.Errorf("failed to resolve secret %q with its command: %w: %s", name, err, strings.TrimSpace(stderr.String()))
+			}
+			value = strings.TrimSpace(stdout.String())
+			resolved.daggerEnv = append(resolved.daggerEnv, secretEnvName(name)+"="+value)
+		}
+		if value == "" {
+			w.logger().WithFields("secret", name).Debug("Secret has no value")
+			continue
+		}
+		trimmed := strings.TrimSpace(value)
+		if len(trimmed) < minSecretLength {
+			return nil, fmt.Errorf("invalid secret %q: its value must have at least %d characters, to be redacted from the outputs", name, minSecretLength)
+		}
+		resolved.values = append(resolved.values, value)
+		if trimmed != value {
+			resolved.values = append(resolved.values, trimmed)
+		}
+	}
+	if w.mason.RedactStore != nil {
+		w.mason.RedactStore.Add(resolved.values...)
+	}
+
+	if w.mason.secrets != nil {
+		w.mason.secrets.Store(w.Dir(), resolved)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,166 @@
+package masonry
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/anchore/go-logger/adapter/redact"
+)
+
+func TestWorkspaceSecrets(t *testing.T) {
+	t.Parallel()
+
+	config := `secrets:
+  registry-token:
+    env: MASON_TEST_UNSET_SECRET
+  signing_key:
+    file: secrets/signing.key
+  github.token:
+    command: echo gh-s3cr3t
+`
+	blueprint := `kind: Publish
+moduleRef: github.com/vbehar/mason-modules/oci@v0.0.5
+metadata:
+  name: image
+  templated: true
+spec:
+  token: '{{ index .secrets "github.token" }}'
+  registryToken: '{{ index .secrets "registry-token" }}'
+`
+
+	rootPath := t.TempDir()
+	writeFile(t, filepath.Join(rootPath, MasonDirName, WorkspaceConfigFileName), config)
+	writeFile(t, filepath.Join(rootPath, MasonDirName, "bricks.yaml"), blueprint)
+	writeFile(t, filepath.Join(rootPath, "secrets", "signing.key"), "key-s3cr3t\n")
+
+	mason := NewMason()
+	mason.DaggerEnv = []string{"FOO=bar"}
+	mason.RedactStore = redact.NewStore()
+	workspace := Workspace{
+		RootPath:     rootPath,
+		RelativePath: ".",
+		mason:        mason,
+	}
+	workspaceConfig, err := workspace.LoadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	workspace.Config = *workspaceConfig
+
+	expectedReferences := map[string]string{
+		"registry-token": "env://MASON_TEST_UNSET_SECRET",
+		"signing_key":    "file://" + filepath.Join(rootPath, "secrets", "signing.key"),
+		"github.token":   "env://MASON_SECRET_GITHUB_TOKEN",
+	}
+	if references := workspace.secretReferences(); !maps.Equal(references, expectedReferences) {
+		t.Errorf("expected references %v, got %v", expectedReferences, references)
+	}
+
+	resolved, err := workspace.resolveSecrets()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"gh-s3cr3t", "key-s3cr3t\n", "key-s3cr3t"}; !slices.Equal(resolved.values, expected) {
+		t.Errorf("expected values %q, got %q", expected, resolved.values)
+	}
+	if expected := []string{"FOO=bar", "MASON_SECRET_GITHUB_TOKEN=gh-s3cr3t"}; !slices.Equal(resolved.daggerEnv, expected) {
+		t.Errorf("expected Dagger env %q, got %q", expected, resolved.daggerEnv)
+	}
+	if redacted := mason.RedactStore.RedactString("token gh-s3cr3t"); strings.Contains(redacted, "gh-s3cr3t") {
+		t.Errorf("expected the secret to be redacted, got %q", redacted)
+	}
+	if cached, _ := workspace.resolveSecrets(); cached != resolved {
+		t.Errorf("expected the secrets to be resolved only once")
+	}
+
+	loadedBlueprint, err := workspace.LoadBlueprint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectedSpec := map[string]any{
+		"token":         "env://MASON_SECRET_GITHUB_TOKEN",
+		"registryToken": "env://MASON_TEST_UNSET_SECRET",
+	}
+	if spec := loadedBlueprint.Bricks[0].Spec; fmt.Sprint(spec) != fmt.Sprint(expectedSpec) {
+		t.Errorf("expected spec %v, got %v", expectedSpec, spec)
+	}
+
+	shortRootPath := t.TempDir()
+	writeFile(t, filepath.Join(shortRootPath, MasonDirName, WorkspaceConfigFileName), "secrets:\n  pin:\n    command: echo 123\n")
+	shortWorkspace := Workspace{
+		RootPath:     shortRootPath,
+		RelativePath: ".",
+		mason:        mason,
+	}
+	shortConfig, err := shortWorkspace.LoadConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	shortWorkspace.Config = *shortConfig
+	_, err = shortWorkspace.resolveSecrets()
+	if err == nil || !strings.Contains(err.Error(), `invalid secret "pin": its value must have at least 4 characters`) {
+		t.Errorf("expected an error for the too short secret, got %v", err)
+	}
+}
+
+func TestWorkspaceLoadConfigWithSecrets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		config        string
+		expectedError string
+	}{
+		{
+			name: "valid secrets",
+			config: `secrets:
+  token:
+    env: TOKEN
+`,
+		},
+		{
+			name: "secret without source",
+			config: `secrets:
+  token: {}
+`,
+			expectedError: `invalid secret "token"`,
+		},
+		{
+			name: "secret with multiple sources",
+			config: `secrets:
+  token:
+    env: TOKEN
+    command: cat token.txt
+`,
+			expectedError: "exactly one of env, file or command must be set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rootPath := t.TempDir()
+			writeFile(t, filepath.Join(rootPath, MasonDirName, WorkspaceConfigFileName), tt.config)
+			workspace := Workspace{
+				RootPath:     rootPath,
+				RelativePath: ".",
+				mason:        NewMason(),
+			}
+			_, err := workspace.LoadConfig()
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error containing %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package masonry
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is the data available to the templated values and the conditions of the bricks:
+//   - env: the environment variables
+//   - vars: the variables of the workspace config, overridden by the mason ones (--set flags)
+//   - git: the git metadata of the workspace - tag, sha, shortSha, describe, branch - when available
+//   - host: the os and arch of the host
+//   - ci: true when running in CI - the CI environment variable is set
+//   - profile: the name of the profile applied to the blueprint, if any
+//   - phases: the requested phases, as a set
+//   - secrets: the Dagger secret references of the secrets of the workspace - env://NAME or file://PATH - by name
+//   - matrix: the values of the matrix combination of the brick - only for the bricks with a matrix
+type TemplateData map[string]any
+
+// forDocument returns the data for the given document, with the values of its matrix combination.
+func (d TemplateData) forDocument(document document) TemplateData {
+	if document.Source.Matrix == nil {
+		return d
+	}
+	data := maps.Clone(d)
+	data["matrix"] = document.Source.Matrix
+	return data
+}
+
+func (w Workspace) templateData() TemplateData {
+	env := make(map[string]string)
+	for _, variable := range os.Environ() {
+		if name, value, ok := strings.Cut(variable, "="); ok {
+			env[name] = value
+		}
+	}
+
+	vars := make(map[string]string)
+	maps.Copy(vars, w.Config.Variables)
+	maps.Copy(vars, w.mason.Variables)
+
+	phases := make(map[string]bool)
+	for _, phase := range w.mason.Phases {
+		phases[phase] = true
+	}
+
+	ci, err := strconv.ParseBool(env["CI"])
+	if err != nil {
+		ci = env["CI"] != ""
+	}
+
+	return TemplateData{
+		"env":     env,
+		"vars":    vars,
+		"git":     w.gitMetadata(),
+		"host":    map[string]string{"os": runtime.GOOS, "arch": runtime.GOARCH},
+		"ci":      ci,
+		"profile": w.mason.Profile,
+		"phases":  phases,
+		"secrets": w.secretReferences(),
+	}
+}
+
+// gitMetadata returns the git metadata of the workspace.
+// The metadata which can't be retrieved - for example the tag if HEAD is not tagged - are missing.
+func (w Workspace) gitMetadata() map[string]string {
+	metadata := make(map[string]string)
+	for name, args := range map[string][]string{
+		"tag":      {"describe", "--tags", "--exact-match"},
+		"sha":      {"rev-parse", "HEAD"},
+		"shortSha": {"rev-parse", "--short", "HEAD"},
+		"describe": {"describe", "--tags", "--always", "--dirty"},
+		"branch":   {"symbolic-ref", "--short", "HEAD"},
+	} {
+		output, err := runGit(w.Dir(), args...)
+		if err != nil {
+			w.logger().WithFields("metadata", name).Tracef("Git metadata not available: %s", err)
+			continue
+		}
+		metadata[name] = strings.TrimSpace(output)
+	}
+	return metadata
+}
+
+// isTemplated returns true if the string values of the document are templates, to render:
+// the bricks opt in with metadata.templated, and the bricks expanded from a matrix always are.
+func (d document) isTemplated() bool {
+	if d.Source.Matrix != nil {
+		return true
+	}
+	metadata, _ := d.Fields["metadata"].(map[string]any)
+	templated, _ := metadata["templated"].(bool)
+	return templated
+}
+
+// render returns a copy of the document, with all its templated string values rendered.
+// Referencing missing data is an error.
+func (d document) render(data TemplateData) (*document, error) {
+	fields, err := renderValue(d.Fields, "", data)
+	if err != nil {
+		return nil, err
+	}
+	d.Fields, _ = fields.(map[string]any)
+	return &d, nil
+}
+
+// renderValue renders the templated string values, without modifying the given value:
+// documents may share values through YAML anchors.
+func renderValue(value any, path string, data TemplateData) (any, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		tmpl, err := template.New(path).Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template in %s: %w", path, err)
+		}
+		var sb strings.Builder
+		err = tmpl.Execute(&sb, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render template in %s: %w", path, err)
+		}
+		return sb.String(), nil
+	case map[string]any:
+		rendered := make(map[string]any, len(v))
+		for key, item := range v {
+			itemPath := key
+			if path != "" {
+				itemPath = path + "." + key
+			}
+			if itemPath == conditionPath {
+				rendered[key] = item // evaluated on its own
+				continue
+			}
+			renderedItem, err := renderValue(item, itemPath, data)
+			if err != nil {
+				return nil, err
+			}
+			rendered[key] = renderedItem
+		}
+		return rendered, nil
+	case []any:
+		rendered := make([]any, len(v))
+		for i, item := range v {
+			renderedItem, err := renderValue(item, fmt.Sprintf("%s[%d]", path, i), data)
+			if err != nil {
+				return nil, err
+			}
+			rendered[i] = renderedItem
+		}
+		return rendered, nil
+	default:
+		return v, nil
+	}
+}
+
+// renderDocuments renders the templated values of the documents defining a brick.
+func (w Workspace) renderDocuments(documents []document) ([]document, error) {
+	var (
+		data TemplateData
+		errs error
+	)
+	for i, document := range documents {
+		if !document.isBrick() || !document.isTemplated() {
+			continue
+		}
+		if data == nil {
+			data = w.templateData()
+		}
+		rendered, err := document.render(data.forDocument(document))
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", document.Source, err))
+			continue
+		}
+		documents[i] = *rendered
+	}
+	if errs != nil {
+		return nil, fmt.Errorf("invalid blueprint: %w", errs)
+	}
+	return documents, nil
+}
@@ -0,0 +1,173 @@
+package masonry
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDocumentRender(t *testing.T) {
+	t.Parallel()
+
+	data := TemplateData{
+		"env":  map[string]string{"HOME": "/home/mason"},
+		"vars": map[string]string{"version": "1.2.3"},
+		"git":  map[string]string{"sha": "4b825dc642cb6eb9a060e54bf8d69288fbee4904", "shortSha": "4b825dc"},
+	}
+
+	tests := []struct {
+		name           string
+		fields         map[string]any
+		expectedFields map[string]any
+		expectedError  string
+	}{
+		{
+			name: "no templates",
+			fields: map[string]any{
+				"kind": "GoBinary",
+				"spec": map[string]any{"cgo": false, "args": []any{"-v", uint64(2)}},
+			},
+			expectedFields: map[string]any{
+				"kind": "GoBinary",
+				"spec": map[string]any{"cgo": false, "args": []any{"-v", uint64(2)}},
+			},
+		},
+		{
+			name: "templates in nested values",
+			fields: map[string]any{
+				"kind": "GoBinary",
+				"metadata": map[string]any{
+					"labels": map[string]any{"commit": "{{ .git.shortSha }}"},
+				},
+				"spec": map[string]any{
+					"buildArgs": []any{"-ldflags", `-X "main.version={{ .vars.version }}" -X "main.commit={{ .git.sha }}"`},
+					"cache":     "{{ .env.HOME }}/.cache",
+				},
+			},
+			expectedFields: map[string]any{
+				"kind": "GoBinary",
+				"metadata": map[string]any{
+					"labels": map[string]any{"commit": "4b825dc"},
+				},
+				"spec": map[string]any{
+					"buildArgs": []any{"-ldflags", `-X "main.version=1.2.3" -X "main.commit=4b825dc642cb6eb9a060e54bf8d69288fbee4904"`},
+					"cache":     "/home/mason/.cache",
+				},
+			},
+		},
+		{
+			name: "missing variable",
+			fields: map[string]any{
+				"spec": map[string]any{
+					"buildArgs": []any{"-ldflags", `-X "main.version={{ .vars.release }}"`},
+				},
+			},
+			expectedError: `failed to render template in spec.buildArgs[1]: template: spec.buildArgs[1]:1:25: executing "spec.buildArgs[1]" at <.vars.release>: map has no entry for key "release"`,
+		},
+		{
+			name: "missing git metadata",
+			fields: map[string]any{
+				"spec": map[string]any{"tag": "{{ .git.tag }}"},
+			},
+			expectedError: `map has no entry for key "tag"`,
+		},
+		{
+			name: "optional environment variable",
+			fields: map[string]any{
+				"spec": map[string]any{"token": `{{ index .env "TOKEN" }}`},
+			},
+			expectedFields: map[string]any{
+				"spec": map[string]any{"token": ""},
+			},
+		},
+		{
+			name: "invalid template",
+			fields: map[string]any{
+				"spec": map[string]any{"tag": "{{ .git.tag "},
+			},
+			expectedError: "invalid template in spec.tag",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			original := fmt.Sprint(tt.fields)
+			doc := document{Fields: tt.fields}
+			rendered, err := doc.render(data)
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Fatalf("expected error to contain %q, got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(rendered.Fields, tt.expectedFields) {
+				t.Errorf("expected fields %v, got %v", tt.expectedFields, rendered.Fields)
+			}
+			if fmt.Sprint(doc.Fields) != original {
+				t.Errorf("expected the original document to be left untouched, got %v", doc.Fields)
+			}
+		})
+	}
+}
+
+func TestWorkspaceLoadBlueprintWithTemplatedBricks(t *testing.T) {
+	t.Parallel()
+
+	rootPath := t.TempDir()
+	writeFile(t, filepath.Join(rootPath, MasonDirName, "bricks.yaml"), `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: templated
+  templated: true
+spec:
+  version: "{{ .vars.version }}"
+  format: '{{"{{"}} .Version {{"}}"}}'
+---
+kind: HelmChart
+moduleRef: helm
+metadata:
+  name: verbatim
+spec:
+  values:
+    image: "{{ .Values.image }}"
+    sha: ${{ github.sha }}
+---
+kind: GoBinary
+moduleRef: golang
+metadata:
+  name: child
+  extends: templated
+`)
+
+	mason := NewMason()
+	mason.Variables = map[string]string{"version": "1.2.3"}
+	workspace := Workspace{
+		RootPath:     rootPath,
+		RelativePath: ".",
+		mason:        mason,
+	}
+	blueprint, err := workspace.LoadBlueprint()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var specs []string
+	for _, brick := range blueprint.Bricks {
+		specs = append(specs, fmt.Sprintf("%s: %v", brick.Metadata.Name, brick.Spec))
+	}
+	expectedSpecs := []string{
+		"templated: map[format:{{ .Version }} version:1.2.3]",
+		"verbatim: map[values:map[image:{{ .Values.image }} sha:${{ github.sha }}]]",
+		"child: map[format:{{ .Version }} version:1.2.3]",
+	}
+	if !reflect.DeepEqual(specs, expectedSpecs) {
+		t.Errorf("expected specs\n%s\ngot\n%s", strings.Join(expectedSpecs, "\n"), strings.Join(specs, "\n"))
+	}
+}
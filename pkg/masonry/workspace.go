@@ -1,19 +1,24 @@
 package masonry
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/anchore/go-logger"
 	"github.com/goccy/go-yaml"
+	"github.com/vbehar/mason/pkg/dagger"
 )
 
 type Workspace struct {
 	RootPath     string
 	RelativePath string // relative to the workspace root
+	Config       WorkspaceConfig
+	Lock         ModuleLock
 
 	mason       *Mason
 	workDirName string
@@ -35,55 +40,161 @@ func (w Workspace) WorkDir() string {
 	return filepath.Join(w.MasonDir(), WorkDirPrefix, w.workDirName)
 }
 
-func (w Workspace) LoadBlueprint() (*Blueprint, error) {
-	w.logger().Debug("Loading blueprint")
-	entries, err := os.ReadDir(w.MasonDir())
+// execDaggerScript runs a Dagger script from the workspace directory,
+// writes Dagger's stderr to the log file, and returns Dagger's stdout.
+// No secrets are resolved: the scripts only read the modules.
+func (w Workspace) execDaggerScript(scriptPath, logFilePath string) (output string, err error) {
+	logFile, err := os.Create(logFilePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read directory %s: %w", w.MasonDir(), err)
+		return "", fmt.Errorf("failed to create log file %q: %w", logFilePath, err)
 	}
-
-	var bricks []Brick
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+	defer func() {
+		err := logFile.Close()
+		if err != nil {
+			w.logger().WithFields("path", logFilePath).
+				Errorf("Failed to close log file: %s", err)
 		}
+	}()
+
+	var daggerOutWriter bytes.Buffer
+	execErr := dagger.ExecScript(dagger.ExecScriptOpts{
+		BinaryPath:    w.mason.DaggerBinary,
+		Logger:        w.logger(),
+		ScriptPath:    scriptPath,
+		Dir:           w.Dir(),
+		Env:           w.mason.DaggerEnv,
+		Args:          w.mason.DaggerArgs,
+		DisableOutput: w.mason.DaggerOutputDisabled,
+		Stdout:        &daggerOutWriter,
+		Stderr:        logFile,
+	})
+	return strings.TrimSpace(daggerOutWriter.String()), execErr
+}
+
+func (w Workspace) ConfigFilePath() string {
+	return filepath.Join(w.MasonDir(), WorkspaceConfigFileName)
+}
+
+// WorkspaceConfig is the workspace-specific configuration, read from the workspace's config file.
+// It shares the file with the CLI configuration, which ignores these keys.
+type WorkspaceConfig struct {
+	// DependsOn is the list of workspaces - relative to the root path - which must be run before this one.
+	DependsOn []string `json:"dependsOn"`
+	// Variables are available to the templated values of the bricks, as .vars.<name>
+	Variables map[string]string `json:"variables"`
+	// Modules are aliases of module references, which can be used as the moduleRef of the bricks
+	Modules map[string]ModuleRef `json:"modules"`
+	// SpecDefaults are the default specs of the bricks, per kind
+	SpecDefaults map[string]map[string]any `json:"specDefaults"`
+	// Secrets are passed to the scripts as Dagger secret references, available as .secrets.<name>
+	Secrets map[string]SecretSource `json:"secrets"`
+}
 
-		if entry.Name() == "config.yaml" {
-			continue // mason's own config file...
+// ResolveModuleRef returns the module reference of an alias, or the given reference if it is not an alias.
+func (c WorkspaceConfig) ResolveModuleRef(moduleRef ModuleRef) ModuleRef {
+	if resolved, ok := c.Modules[string(moduleRef)]; ok {
+		return resolved
+	}
+	return moduleRef
+}
+
+// SpecDefaultsForKind returns the default spec of the bricks of the given kind - case-insensitive - if any.
+func (c WorkspaceConfig) SpecDefaultsForKind(kind string) (map[string]any, bool) {
+	for defaultsKind, defaults := range c.SpecDefaults {
+		if strings.EqualFold(defaultsKind, kind) {
+			return defaults, true
 		}
+	}
+	return nil, false
+}
 
-		switch filepath.Ext(entry.Name()) {
-		case ".json", ".yaml", ".yml":
-			// valid file types
-		default:
-			continue
+func (w Workspace) LoadConfig() (*WorkspaceConfig, error) {
+	var config WorkspaceConfig
+	content, err := os.ReadFile(w.ConfigFilePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return &config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", w.ConfigFilePath(), err)
+	}
+
+	err = yaml.Unmarshal(content, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode config file %s: %w", w.ConfigFilePath(), err)
+	}
+	for i, dependency := range config.DependsOn {
+		config.DependsOn[i] = filepath.Clean(dependency)
+	}
+	for name, source := range config.Secrets {
+		if err := source.validate(); err != nil {
+			return nil, fmt.Errorf("invalid secret %q in config file %s: %w", name, w.ConfigFilePath(), err)
 		}
+	}
+	return &config, nil
+}
 
-		w.logger().WithFields("file", entry.Name()).Trace("Loading file")
-		f, err := os.Open(filepath.Join(w.MasonDir(), entry.Name()))
+// LoadBlueprintOpts are the options to load the blueprint of a workspace.
+type LoadBlueprintOpts struct {
+	// SkipConditions keeps the bricks whose condition is false,
+	// for the commands not running phases - such as lock - which need all the bricks.
+	SkipConditions bool
+}
+
+func (w Workspace) LoadBlueprint() (*Blueprint, error) {
+	return w.LoadBlueprintWithOpts(LoadBlueprintOpts{})
+}
+
+func (w Workspace) LoadBlueprintWithOpts(opts LoadBlueprintOpts) (*Blueprint, error) {
+	w.logger().Debug("Loading blueprint")
+	documents, err := w.loadDocuments()
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err = w.resolveExtends(documents)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.mason.Profile != "" {
+		documents, err = w.applyProfile(documents, w.mason.Profile)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open file %s: %w", entry.Name(), err)
+			return nil, err
 		}
-		defer f.Close() //nolint:errcheck // we're just reading the file...
-
-		decoder := yaml.NewDecoder(f)
-		for doc := 0; ; doc++ {
-			var brick Brick
-			err = decoder.Decode(&brick)
-			if errors.Is(err, io.EOF) {
-				break
-			}
-			if err != nil {
-				return nil, fmt.Errorf("failed to decode document %d from file %s: %w", doc, entry.Name(), err)
-			}
-			if brick.IsValid() {
-				w.logger().WithFields("name", brick.Metadata.Name, "kind", brick.Kind).
-					Debug("Loaded brick")
-				bricks = append(bricks, brick)
-			}
+	}
+
+	documents, err = w.applySpecDefaults(documents)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err = w.expandMatrices(documents)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err = w.renderDocuments(documents)
+	if err != nil {
+		return nil, err
+	}
+
+	var excludedNames map[string]bool
+	if !opts.SkipConditions {
+		documents, excludedNames, err = w.applyConditions(documents)
+		if err != nil {
+			return nil, err
 		}
 	}
 
+	bricks, err := w.decodeBricks(documents, opts.SkipConditions)
+	if err != nil {
+		return nil, err
+	}
+	err = checkBrickDependencies(bricks, excludedNames)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blueprint: %w", err)
+	}
+
 	w.logger().WithFields("bricks", len(bricks)).Info("Loaded bricks")
 	return &Blueprint{
 		Bricks:    bricks,
@@ -0,0 +1,570 @@
+package masonry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWorkspaceLoadBlueprint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		files           map[string]string
+		strict          bool
+		expectedBricks  []string
+		expectedSources []string
+		expectedErrors  []string
+	}{
+		{
+			name:   "valid bricks and anchors",
+			strict: true,
+			files: map[string]string{
+				"config.yaml": "dependsOn: []\n",
+				"golang.yaml": `commonSpec: &commonSpec
+  cgo: false
+---
+kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+spec:
+  <<: *commonSpec
+  os: linux
+---
+kind: GoTest
+moduleRef: golang
+metadata:
+  name: unit
+`,
+				"lint.json": `{"kind": "GoLint", "moduleRef": "golang", "metadata": {"name": "lint"}}`,
+			},
+			expectedBricks:  []string{"GoBinary/linux", "GoTest/unit", "GoLint/lint"},
+			expectedSources: []string{"golang.yaml (document 1)", "golang.yaml (document 2)", "lint.json (document 0)"},
+		},
+		{
+			name:   "subdirectories",
+			strict: true,
+			files: map[string]string{
+				".masonignore": "# drafts\ndrafts/\n*.draft.yaml\n",
+				"bricks/golang/binaries.yaml": `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+`,
+				"bricks/release/release.yaml": `kind: Release
+moduleRef: release
+metadata:
+  name: github
+`,
+				"bricks/release/next.draft.yaml": `kind: Release
+moduleRef: release
+`,
+				"bricks/drafts/invalid.yaml": `kind: GoTest
+`,
+				".work/abc/blueprint/golang/gobinary_linux.json": `{"kind": "GoBinary"}`,
+				"bricks/golang/config.yaml": `kind: GoLint
+moduleRef: golang
+metadata:
+  name: lint
+`,
+			},
+			expectedBricks:  []string{"GoBinary/linux", "GoLint/lint", "Release/github"},
+			expectedSources: []string{"bricks/golang/binaries.yaml (document 0)", "bricks/golang/config.yaml (document 0)", "bricks/release/release.yaml (document 0)"},
+		},
+		{
+			name:   "re-included file",
+			strict: true,
+			files: map[string]string{
+				".masonignore": "bricks/experimental/**\n!bricks/experimental/keep.yaml\n",
+				"bricks/experimental/new.yaml": `kind: GoTest
+`,
+				"bricks/experimental/keep.yaml": `kind: GoLint
+moduleRef: golang
+metadata:
+  name: lint
+`,
+			},
+			expectedBricks:  []string{"GoLint/lint"},
+			expectedSources: []string{"bricks/experimental/keep.yaml (document 0)"},
+		},
+		{
+			name:   "local includes",
+			strict: true,
+			files: map[string]string{
+				".masonignore": "shared/\n",
+				"golang.yaml": `include:
+  - shared/lint.yaml
+  - path: ../common/test.yaml
+---
+kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+`,
+				"shared/lint.yaml": `kind: GoLint
+moduleRef: golang
+metadata:
+  name: lint
+`,
+				"test.yaml": "include: [shared/lint.yaml]\n",
+				"../common/test.yaml": `kind: GoTest
+moduleRef: golang
+metadata:
+  name: unit
+`,
+			},
+			expectedBricks: []string{"GoLint/lint", "GoTest/unit", "GoBinary/linux"},
+			expectedSources: []string{
+				"shared/lint.yaml (document 0), included from golang.yaml (document 0)",
+				"../common/test.yaml (document 0), included from golang.yaml (document 0)",
+				"golang.yaml (document 1)",
+			},
+		},
+		{
+			name:   "include cycle",
+			strict: true,
+			files: map[string]string{
+				".masonignore":  "shared/\n",
+				"golang.yaml":   "include: [shared/a.yaml]\n",
+				"shared/a.yaml": "include: [b.yaml]\n",
+				"shared/b.yaml": "include: [a.yaml]\n",
+			},
+			expectedErrors: []string{
+				"include cycle: shared/a.yaml includes itself, through shared/b.yaml (document 0), shared/a.yaml (document 0), golang.yaml (document 0)",
+			},
+		},
+		{
+			name:   "invalid include",
+			strict: true,
+			files: map[string]string{
+				"golang.yaml": "include:\n  - url: https://example.com/lint.yaml\n",
+			},
+			expectedErrors: []string{
+				`golang.yaml (document 0): invalid include: invalid digest "" for URL https://example.com/lint.yaml`,
+			},
+		},
+		{
+			name:   "missing fields",
+			strict: true,
+			files: map[string]string{
+				"golang.yaml": `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+---
+kind: GoTest
+metadata:
+  labels:
+    type: unit
+`,
+			},
+			expectedErrors: []string{
+				"golang.yaml (document 1): invalid brick: missing moduleRef, metadata.name",
+			},
+		},
+		{
+			name:   "unknown field",
+			strict: true,
+			files: map[string]string{
+				"golang.yaml": `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+  label:
+    os: linux
+`,
+			},
+			expectedErrors: []string{
+				`golang.yaml (document 0): invalid brick: json: unknown field "label"`,
+			},
+		},
+		{
+			name:   "duplicate bricks",
+			strict: true,
+			files: map[string]string{
+				"a.yaml": `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+`,
+				"b.yaml": `kind: gobinary
+moduleRef: golang
+metadata:
+  name: Linux
+`,
+			},
+			expectedErrors: []string{
+				"b.yaml (document 0): duplicate brick gobinary Linux, already defined in a.yaml (document 0)",
+			},
+		},
+		{
+			name:   "invalid and duplicate bricks are skipped when not strict",
+			strict: false,
+			files: map[string]string{
+				"a.yaml": `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+---
+kind: GoTest
+moduleRef: golang
+`,
+				"b.yaml": `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+`,
+			},
+			expectedBricks:  []string{"GoBinary/linux"},
+			expectedSources: []string{"a.yaml (document 0)"},
+		},
+		{
+			name:   "dependencies",
+			strict: true,
+			files: map[string]string{
+				"bricks.yaml": `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+---
+kind: Docs
+moduleRef: docs
+metadata:
+  name: docs
+  dependsOn: [Linux]
+`,
+			},
+			expectedBricks:  []string{"GoBinary/linux", "Docs/docs"},
+			expectedSources: []string{"bricks.yaml (document 0)", "bricks.yaml (document 1)"},
+		},
+		{
+			name:   "unknown dependency",
+			strict: true,
+			files: map[string]string{
+				"bricks.yaml": `kind: Docs
+moduleRef: docs
+metadata:
+  name: docs
+  dependsOn: [linux]
+`,
+			},
+			expectedErrors: []string{`bricks.yaml (document 0): invalid brick: unknown brick "linux" in metadata.dependsOn`},
+		},
+		{
+			name:   "dependency cycle",
+			strict: true,
+			files: map[string]string{
+				"bricks.yaml": `kind: GoBinary
+moduleRef: golang
+metadata:
+  name: linux
+  dependsOn: [release]
+---
+kind: Docs
+moduleRef: docs
+metadata:
+  name: docs
+  dependsOn: [linux]
+---
+kind: Release
+moduleRef: release
+metadata:
+  name: release
+  dependsOn: [docs]
+`,
+			},
+			expectedErrors: []string{"dependency cycle between bricks: linux → release → docs → linux"},
+		},
+		{
+			name:   "timeout and retries",
+			strict: true,
+			files: map[string]string{
+				"bricks.yaml": `kind: RunBinary
+moduleRef: run
+metadata:
+  name: version
+  timeout: 1m30s
+  retries: 2
+---
+kind: RunBinary
+moduleRef: run
+metadata:
+  name: flaky
+  timeout: 10
+---
+kind: RunBinary
+moduleRef: run
+metadata:
+  name: hanging
+  timeout: -1m
+---
+kind: RunBinary
+moduleRef: run
+metadata:
+  name: negative
+  retries: -1
+---
+kind: PipelineDebug
+moduleRef: llm
+metadata:
+  name: debug
+  postRun: on_failure
+  retries: 1
+`,
+			},
+			expectedErrors: []string{
+				`bricks.yaml (document 1): invalid brick: invalid duration 10: must be a string such as "90s" or "1h30m"`,
+				`bricks.yaml (document 2): invalid brick: invalid duration "-1m": must be positive`,
+				"bricks.yaml (document 3): invalid brick: metadata.retries must be positive",
+				"bricks.yaml (document 4): invalid brick: metadata.timeout and metadata.retries are not supported for post-run bricks",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rootPath := t.TempDir()
+			masonDir := filepath.Join(rootPath, MasonDirName)
+			if err := os.MkdirAll(masonDir, os.ModePerm); err != nil {
+				t.Fatalf("failed to create directory: %v", err)
+			}
+			for name, content := range tt.files {
+				writeFile(t, filepath.Join(masonDir, filepath.FromSlash(name)), content)
+			}
+
+			mason := NewMason()
+			mason.StrictBlueprint = tt.strict
+			mason.CacheDir = t.TempDir()
+			workspace := Workspace{
+				RootPath:     rootPath,
+				RelativePath: ".",
+				mason:        mason,
+			}
+
+			blueprint, err := workspace.LoadBlueprint()
+			if len(tt.expectedErrors) > 0 {
+				if err == nil {
+					t.Fatalf("expected errors %v, got nil", tt.expectedErrors)
+				}
+				for _, expectedError := range tt.expectedErrors {
+					if !strings.Contains(err.Error(), expectedError) {
+						t.Errorf("expected error to contain %q, got %v", expectedError, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var bricks, sources []string
+			for _, brick := range blueprint.Bricks {
+				bricks = append(bricks, brick.Kind+"/"+brick.Metadata.Name)
+				sources = append(sources, brick.Source.String())
+			}
+			if !slices.Equal(bricks, tt.expectedBricks) {
+				t.Errorf("expected bricks %v, got %v", tt.expectedBricks, bricks)
+			}
+			if !slices.Equal(sources, tt.expectedSources) {
+				t.Errorf("expected sources %v, got %v", tt.expectedSources, sources)
+			}
+		})
+	}
+}
+
+func TestWorkspaceLoadBlueprintWithRemoteIncludes(t *testing.T) {
+	t.Parallel()
+
+	lintContent := []byte(`kind: GoLint
+moduleRef: golang
+metadata:
+  name: lint
+`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(lintContent)
+	}))
+	t.Cleanup(server.Close)
+
+	repositoryDir := t.TempDir()
+	writeFile(t, filepath.Join(repositoryDir, "mason", "test.yaml"), "include: [../common/binary.yaml]\n---\n"+
+		"kind: GoTest\nmoduleRef: golang\nmetadata:\n  name: unit\n")
+	writeFile(t, filepath.Join(repositoryDir, "common", "binary.yaml"), "kind: GoBinary\nmoduleRef: golang\nmetadata:\n  name: linux\n")
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "init"},
+	} {
+		if _, err := runGit(repositoryDir, args...); err != nil {
+			t.Fatalf("failed to create git repository: %v", err)
+		}
+	}
+	commit, err := runGit(repositoryDir, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatalf("failed to get commit: %v", err)
+	}
+	commit = strings.TrimSpace(commit)
+	repositoryHash := sha256.Sum256([]byte(repositoryDir))
+	gitCacheDir := path.Join("includes", "git", hex.EncodeToString(repositoryHash[:8]), commit)
+
+	tests := []struct {
+		name            string
+		blueprint       string
+		expectedBricks  []string
+		expectedSources []string
+		expectedCache   []string
+		expectedError   string
+	}{
+		{
+			name: "http",
+			blueprint: fmt.Sprintf("include:\n  - url: %s/lint.yaml\n    digest: %s\n",
+				server.URL, sha256Digest(lintContent)),
+			expectedBricks:  []string{"GoLint/lint"},
+			expectedSources: []string{server.URL + "/lint.yaml (document 0), included from golang.yaml (document 0)"},
+			expectedCache:   []string{path.Join("includes", "sha256", strings.TrimPrefix(sha256Digest(lintContent), "sha256:"))},
+		},
+		{
+			name: "http with invalid digest",
+			blueprint: fmt.Sprintf("include:\n  - url: %s/lint.yaml\n    digest: %s\n",
+				server.URL, sha256Digest([]byte("something else"))),
+			expectedError: "digest mismatch for " + server.URL + "/lint.yaml",
+		},
+		{
+			name: "git",
+			blueprint: fmt.Sprintf("include:\n  - git: %s\n    ref: %s\n    path: mason/test.yaml\n",
+				repositoryDir, commit),
+			expectedBricks: []string{"GoBinary/linux", "GoTest/unit"},
+			expectedSources: []string{
+				fmt.Sprintf("git::%s//common/binary.yaml?ref=%s (document 0), included from git::%s//mason/test.yaml?ref=%s (document 0), included from golang.yaml (document 0)",
+					repositoryDir, commit, repositoryDir, commit),
+				fmt.Sprintf("git::%s//mason/test.yaml?ref=%s (document 1), included from golang.yaml (document 0)",
+					repositoryDir, commit),
+			},
+			expectedCache: []string{path.Join(gitCacheDir, "common", "binary.yaml"), path.Join(gitCacheDir, "mason", "test.yaml")},
+		},
+		{
+			name: "git path outside of the repository",
+			blueprint: fmt.Sprintf("include:\n  - git: %s\n    ref: %s\n    path: ../test.yaml\n",
+				repositoryDir, commit),
+			expectedError: "path ../test.yaml is outside of the git repository",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rootPath := t.TempDir()
+			writeFile(t, filepath.Join(rootPath, MasonDirName, "golang.yaml"), tt.blueprint)
+
+			mason := NewMason()
+			mason.CacheDir = t.TempDir()
+			workspace := Workspace{
+				RootPath:     rootPath,
+				RelativePath: ".",
+				mason:        mason,
+			}
+
+			// the second load uses the cache: the cached files are not written again
+			var cachedFiles map[string]time.Time
+			for i := range 2 {
+				blueprint, err := workspace.LoadBlueprint()
+				if tt.expectedError != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+						t.Fatalf("expected error to contain %q, got %v", tt.expectedError, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				var bricks, sources []string
+				for _, brick := range blueprint.Bricks {
+					bricks = append(bricks, brick.Kind+"/"+brick.Metadata.Name)
+					sources = append(sources, brick.Source.String())
+				}
+				if !slices.Equal(bricks, tt.expectedBricks) {
+					t.Errorf("expected bricks %v, got %v", tt.expectedBricks, bricks)
+				}
+				if !slices.Equal(sources, tt.expectedSources) {
+					t.Errorf("expected sources %v, got %v", tt.expectedSources, sources)
+				}
+
+				if i == 0 {
+					cachedFiles = listCachedFiles(t, mason.CacheDir, true)
+					if files := slices.Sorted(maps.Keys(cachedFiles)); !slices.Equal(files, tt.expectedCache) {
+						t.Fatalf("expected cached files %v, got %v", tt.expectedCache, files)
+					}
+					continue
+				}
+				if files := listCachedFiles(t, mason.CacheDir, false); !maps.Equal(files, cachedFiles) {
+					t.Errorf("expected the cached files to be reused: %v, got %v", cachedFiles, files)
+				}
+			}
+		})
+	}
+}
+
+// listCachedFiles returns the modification times of the files of a cache directory, by relative path.
+// The files of the git repositories are ignored. If reset is set, the modification times are reset first,
+// to detect the files written again.
+func listCachedFiles(t *testing.T, cacheDir string, reset bool) map[string]time.Time {
+	t.Helper()
+	cachedFiles := make(map[string]time.Time)
+	err := filepath.WalkDir(cacheDir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if reset {
+			modTime := time.Unix(0, 0)
+			if err := os.Chtimes(filePath, modTime, modTime); err != nil {
+				return err
+			}
+		}
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(cacheDir, filePath)
+		if err != nil {
+			return err
+		}
+		cachedFiles[filepath.ToSlash(relativePath)] = info.ModTime()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to list the cached files: %v", err)
+	}
+	return cachedFiles
+}
+
+func writeFile(t *testing.T, filePath, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		t.Fatalf("failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file %s: %v", filePath, err)
+	}
+}